@@ -23,6 +23,20 @@ func main() {
 
 	rootCmd.AddCommand(commands.Create())
 	rootCmd.AddCommand(commands.Update())
+	rootCmd.AddCommand(commands.List())
+	rootCmd.AddCommand(commands.Search())
+	rootCmd.AddCommand(commands.Info())
+	rootCmd.AddCommand(commands.Yank())
+	rootCmd.AddCommand(commands.Delete())
+	rootCmd.AddCommand(commands.Tag())
+	rootCmd.AddCommand(commands.Prune())
+	rootCmd.AddCommand(commands.Registry())
+	rootCmd.AddCommand(commands.Uninstall())
+	rootCmd.AddCommand(commands.Rollback())
+	rootCmd.AddCommand(commands.History())
+	rootCmd.AddCommand(commands.Cache())
+	rootCmd.AddCommand(commands.Env())
+	rootCmd.AddCommand(commands.Verify())
 
 	rootCmd.Execute()
 }
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type LockedPackage struct {
+	Name     string `json:"name"`
+	Version  string `json:"ver"`
+	Archive  string `json:"archive"`
+	Checksum string `json:"sha256"`
+}
+
+type Lockfile struct {
+	Packages []LockedPackage `json:"packages"`
+}
+
+// LockfilePath returns the lockfile path belonging to a packages file,
+// e.g. packages.lock.json for packages.json
+func LockfilePath(packagesPath string) string {
+	dot := strings.LastIndex(packagesPath, ".")
+	if dot <= strings.LastIndexAny(packagesPath, `/\`) {
+		return packagesPath + ".lock"
+	}
+	return packagesPath[:dot] + ".lock" + packagesPath[dot:]
+}
+
+// Find returns the locked package with the given name
+func (l *Lockfile) Find(name string) (LockedPackage, bool) {
+	for _, pkg := range l.Packages {
+		if pkg.Name == name {
+			return pkg, true
+		}
+	}
+	return LockedPackage{}, false
+}
+
+// LoadLockfile reads a lockfile. A missing lockfile is treated as empty.
+func LoadLockfile(filepath string) (*Lockfile, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Lockfile{}, nil
+		}
+		return nil, fmt.Errorf("failed to read lockfile: %w", err)
+	}
+
+	var lockfile Lockfile
+	if err := json.Unmarshal(data, &lockfile); err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile: %w", err)
+	}
+
+	return &lockfile, nil
+}
+
+func SaveLockfile(filepath string, lockfile *Lockfile) error {
+	data, err := json.MarshalIndent(lockfile, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode lockfile: %w", err)
+	}
+
+	if err := os.WriteFile(filepath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+
+	return nil
+}
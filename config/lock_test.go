@@ -0,0 +1,74 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLockfilePath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "packages.json", expected: "packages.lock.json"},
+		{input: "deploy/packages.json", expected: "deploy/packages.lock.json"},
+		{input: "conf.d/packages", expected: "conf.d/packages.lock"},
+	}
+
+	for _, tt := range tests {
+		if got := LockfilePath(tt.input); got != tt.expected {
+			t.Errorf("LockfilePath(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestLockfile_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "packages.lock.json")
+
+	lockfile := &Lockfile{
+		Packages: []LockedPackage{
+			{Name: "package1", Version: "1.2.0", Archive: "package1-1.2.0.tar.gz", Checksum: "abc"},
+		},
+	}
+
+	if err := SaveLockfile(path, lockfile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, err := LoadLockfile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, lockfile) {
+		t.Errorf("got %+v, want %+v", loaded, lockfile)
+	}
+
+	locked, ok := loaded.Find("package1")
+	if !ok || locked.Version != "1.2.0" {
+		t.Errorf("Find() = %+v, %v", locked, ok)
+	}
+	if _, ok := loaded.Find("missing"); ok {
+		t.Errorf("Find() found missing package")
+	}
+}
+
+func TestLoadLockfile_Missing(t *testing.T) {
+	lockfile, err := LoadLockfile(filepath.Join(t.TempDir(), "missing.lock.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lockfile.Packages) != 0 {
+		t.Errorf("expected empty lockfile, got %+v", lockfile)
+	}
+}
+
+func TestLoadLockfile_Invalid(t *testing.T) {
+	tmpFile := createTempFile(t, "invalid*.lock.json", "{invalid json}")
+	defer os.Remove(tmpFile)
+
+	if _, err := LoadLockfile(tmpFile); err == nil || !containsString(err.Error(), "failed to parse lockfile") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
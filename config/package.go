@@ -33,20 +33,46 @@ type Dependency struct {
 	Version string `json:"ver,omitempty"`
 }
 
+// PacketScripts lists the lifecycle scripts of a package. Each script is a
+// local file path that gets embedded in the package archive.
+type PacketScripts struct {
+	Preinstall   string `json:"preinstall,omitempty"`
+	Postinstall  string `json:"postinstall,omitempty"`
+	Preuninstall string `json:"preuninstall,omitempty"`
+}
+
 type PacketConfig struct {
 	Name         string         `json:"name"`
 	Version      string         `json:"ver"`
+	Description  string         `json:"description,omitempty"`
+	Keywords     []string       `json:"keywords,omitempty"`
+	License      string         `json:"license,omitempty"`
+	Maintainers  []string       `json:"maintainers,omitempty"`
 	Targets      []PacketTarget `json:"targets"`
 	Dependencies []Dependency   `json:"packets,omitempty"`
+	Scripts      *PacketScripts `json:"scripts,omitempty"`
+	// Replaces names packages whose files this package may take over
+	Replaces []string `json:"replaces,omitempty"`
+	// Bin maps command names to executables inside the package
+	Bin map[string]string `json:"bin,omitempty"`
+	// ConfigFiles are package paths whose local edits survive upgrades
+	ConfigFiles []string `json:"config_files,omitempty"`
 }
 
 type PackageRequest struct {
 	Name    string `json:"name"`
 	Version string `json:"ver,omitempty"`
+	// Tag resolves the version through a distribution tag such as "stable"
+	Tag  string `json:"tag,omitempty"`
+	Dest string `json:"dest,omitempty"`
 }
 
 type PackagesConfig struct {
-	Packages []PackageRequest `json:"packages"`
+	Packages     []PackageRequest `json:"packages"`
+	InstallDir   string           `json:"install_dir,omitempty"`
+	KeepVersions int              `json:"keep_versions,omitempty"`
+	// TrustedPackages may run their lifecycle scripts without --allow-scripts
+	TrustedPackages []string `json:"trusted_packages,omitempty"`
 }
 
 func LoadPacketConfig(filepath string) (*PacketConfig, error) {
@@ -90,3 +116,51 @@ func LoadPackagesConfig(filepath string) (*PackagesConfig, error) {
 
 	return &config, nil
 }
+
+// IsTrusted reports whether the named package may run lifecycle scripts
+func (pc *PackagesConfig) IsTrusted(name string) bool {
+	for _, trusted := range pc.TrustedPackages {
+		if trusted == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RemovePackage removes the request for the named package.
+// It reports whether a request was removed.
+func (pc *PackagesConfig) RemovePackage(name string) bool {
+	removed := false
+	packages := pc.Packages[:0]
+	for _, pkg := range pc.Packages {
+		if pkg.Name == name {
+			removed = true
+			continue
+		}
+		packages = append(packages, pkg)
+	}
+	pc.Packages = packages
+	return removed
+}
+
+func SavePackagesConfig(filepath string, config *PackagesConfig) error {
+	ext := strings.ToLower(filepath[strings.LastIndex(filepath, ".")+1:])
+
+	var data []byte
+	var err error
+	switch ext {
+	case "json":
+		data, err = json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode JSON packages: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported packages file format: %s", ext)
+	}
+
+	if err := os.WriteFile(filepath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write packages file: %w", err)
+	}
+
+	return nil
+}
@@ -338,6 +338,84 @@ func TestEmptyConfigs(t *testing.T) {
 	}
 }
 
+func TestLoadPackagesConfig_InstallDestinations(t *testing.T) {
+	jsonConfig := `{
+		"install_dir": "/opt/tools",
+		"packages": [
+			{"name": "package1", "dest": "bin"},
+			{"name": "package2"}
+		]
+	}`
+
+	tmpFile := createTempFile(t, "packages*.json", jsonConfig)
+	defer os.Remove(tmpFile)
+
+	config, err := LoadPackagesConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := &PackagesConfig{
+		InstallDir: "/opt/tools",
+		Packages: []PackageRequest{
+			{Name: "package1", Dest: "bin"},
+			{Name: "package2"},
+		},
+	}
+
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("got %+v, want %+v", config, expected)
+	}
+}
+
+func TestPackagesConfig_RemovePackage(t *testing.T) {
+	config := &PackagesConfig{
+		Packages: []PackageRequest{
+			{Name: "package1", Version: "1.0.0"},
+			{Name: "package2"},
+		},
+	}
+
+	if !config.RemovePackage("package1") {
+		t.Errorf("expected package1 to be removed")
+	}
+	if config.RemovePackage("missing") {
+		t.Errorf("expected missing package not to be removed")
+	}
+
+	expected := []PackageRequest{{Name: "package2"}}
+	if !reflect.DeepEqual(config.Packages, expected) {
+		t.Errorf("got %+v, want %+v", config.Packages, expected)
+	}
+}
+
+func TestSavePackagesConfig(t *testing.T) {
+	config := &PackagesConfig{
+		Packages: []PackageRequest{
+			{Name: "package1", Version: ">=1.0.0"},
+		},
+	}
+
+	tmpFile := createTempFile(t, "packages*.json", "{}")
+	defer os.Remove(tmpFile)
+
+	if err := SavePackagesConfig(tmpFile, config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, err := LoadPackagesConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, config) {
+		t.Errorf("got %+v, want %+v", loaded, config)
+	}
+
+	if err := SavePackagesConfig("packages.txt", config); err == nil {
+		t.Errorf("expected error for unsupported format")
+	}
+}
+
 // Helper functions
 
 func createTempFile(t *testing.T, pattern, content string) string {
@@ -13,6 +13,14 @@ type SSHConfig struct {
 	KeyPath   string        `json:"key_path"`
 	Timeout   time.Duration `json:"timeout"`
 	RemoteDir string        `json:"remote_dir"`
+	// Retries is how often an interrupted transfer is resumed after
+	// reconnecting. Zero uses the default and a negative value disables it.
+	Retries int `json:"retries,omitempty"`
+	// RetryDelay is the number of seconds to wait before the first retry,
+	// doubled for every further one
+	RetryDelay int `json:"retry_delay,omitempty"`
+	// LimitRate caps the combined rate of all transfers, e.g. "2MB/s"
+	LimitRate string `json:"limit_rate,omitempty"`
 }
 
 func LoadSSHConfig(configPath string) (*SSHConfig, error) {
@@ -0,0 +1,296 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rasadov/package-manager/internal/utils"
+)
+
+const (
+	indexFileName    = "index.json"
+	blobsDirName     = "blobs"
+	downloadsDirName = "downloads"
+	// claimSuffix marks the file claiming the shared download of an archive
+	claimSuffix = ".claim"
+)
+
+// Entry describes a cached package archive
+type Entry struct {
+	Archive   string    `json:"archive"`
+	Name      string    `json:"name"`
+	Version   string    `json:"ver"`
+	Checksum  string    `json:"sha256"`
+	Size      int64     `json:"size"`
+	FetchedAt time.Time `json:"fetched_at"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+// Cache is a content-addressed store of downloaded package archives.
+// Archives are stored as blobs/<sha256>/<archive name> and indexed by
+// archive name. It is safe for concurrent use.
+type Cache struct {
+	dir     string
+	mu      sync.Mutex
+	Entries map[string]*Entry `json:"entries"`
+}
+
+// DefaultDir returns the cache directory, $XDG_CACHE_HOME/pm on Linux
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine cache directory: %w", err)
+	}
+	return filepath.Join(dir, "pm"), nil
+}
+
+// Open loads the cache index in dir. A missing cache is treated as empty.
+func Open(dir string) (*Cache, error) {
+	c := &Cache{
+		dir:     dir,
+		Entries: make(map[string]*Entry),
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, indexFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, fmt.Errorf("failed to read cache index: %w", err)
+	}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse cache index: %w", err)
+	}
+	if c.Entries == nil {
+		c.Entries = make(map[string]*Entry)
+	}
+
+	return c, nil
+}
+
+// Dir returns the cache directory
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Path returns the location of the blob of an entry
+func (c *Cache) Path(entry *Entry) string {
+	return filepath.Join(c.dir, blobsDirName, entry.Checksum, entry.Archive)
+}
+
+// ClaimDownload returns where to download an archive before it is added,
+// and a function that releases the claim. The first process to claim an
+// archive downloads to a shared path, so an interrupted download can be
+// resumed by a later run; processes downloading the same archive at the
+// same time get a directory of their own.
+func (c *Cache) ClaimDownload(archive string) (string, func(), error) {
+	downloadsDir := filepath.Join(c.dir, downloadsDirName)
+	if err := os.MkdirAll(downloadsDir, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create downloads directory: %w", err)
+	}
+
+	claimPath := filepath.Join(downloadsDir, archive+claimSuffix)
+	for attempt := 0; attempt < 2; attempt++ {
+		claim, err := os.OpenFile(claimPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintf(claim, "%d\n", os.Getpid())
+			claim.Close()
+			return filepath.Join(downloadsDir, archive), func() { os.Remove(claimPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return "", nil, fmt.Errorf("failed to claim download of %s: %w", archive, err)
+		}
+
+		// A claim left by an interrupted process is taken over
+		if claimHeld(claimPath) {
+			break
+		}
+		os.Remove(claimPath)
+	}
+
+	privateDir := filepath.Join(downloadsDir, fmt.Sprintf("%s.%d", archive, os.Getpid()))
+	if err := os.MkdirAll(privateDir, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create downloads directory: %w", err)
+	}
+	return filepath.Join(privateDir, archive), func() { os.RemoveAll(privateDir) }, nil
+}
+
+// claimHeld reports whether the process recorded in a download claim is
+// still running
+func claimHeld(claimPath string) bool {
+	data, err := os.ReadFile(claimPath)
+	if err != nil {
+		// The holder released the claim in the meantime
+		return !os.IsNotExist(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		// A claim is being written
+		return true
+	}
+	return utils.ProcessAlive(pid)
+}
+
+// Lookup returns the cached archive with the given name. Entries whose blob
+// is missing are dropped from the index.
+func (c *Cache) Lookup(archive string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.Entries[archive]
+	if !ok {
+		return nil, false
+	}
+	if _, err := os.Stat(c.Path(entry)); err != nil {
+		delete(c.Entries, archive)
+		return nil, false
+	}
+	return entry, true
+}
+
+// Touch marks an entry as used now
+func (c *Cache) Touch(entry *Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.LastUsed = time.Now().UTC()
+}
+
+// Add copies a downloaded archive into the cache and indexes it
+func (c *Cache) Add(name, version, archivePath string) (*Entry, error) {
+	checksum, err := utils.FileChecksum(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum archive: %w", err)
+	}
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat archive: %w", err)
+	}
+
+	now := time.Now().UTC()
+	entry := &Entry{
+		Archive:   filepath.Base(archivePath),
+		Name:      name,
+		Version:   version,
+		Checksum:  checksum,
+		Size:      info.Size(),
+		FetchedAt: now,
+		LastUsed:  now,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	blobPath := c.Path(entry)
+	if _, err := os.Stat(blobPath); os.IsNotExist(err) {
+		tmpPath := blobPath + ".tmp"
+		if err := utils.CopyFile(archivePath, tmpPath); err != nil {
+			os.Remove(tmpPath)
+			return nil, fmt.Errorf("failed to copy archive to cache: %w", err)
+		}
+		if err := os.Rename(tmpPath, blobPath); err != nil {
+			os.Remove(tmpPath)
+			return nil, fmt.Errorf("failed to move archive into cache: %w", err)
+		}
+	}
+
+	c.Entries[entry.Archive] = entry
+	return entry, nil
+}
+
+// List returns all cache entries sorted by archive name
+func (c *Cache) List() []*Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]*Entry, 0, len(c.Entries))
+	for _, entry := range c.Entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Archive < entries[j].Archive
+	})
+	return entries
+}
+
+// Remove deletes an entry and its blob. The checksum directory is kept
+// while other archives with the same content are stored in it.
+func (c *Cache) Remove(entry *Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	blobPath := c.Path(entry)
+	if err := os.Remove(blobPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove cached archive %s: %w", entry.Archive, err)
+	}
+	// Fails harmlessly if the directory is not empty
+	os.Remove(filepath.Dir(blobPath))
+
+	delete(c.Entries, entry.Archive)
+	return nil
+}
+
+// Prune removes entries not used within maxAge and returns them
+func (c *Cache) Prune(maxAge time.Duration) ([]*Entry, error) {
+	cutoff := time.Now().Add(-maxAge)
+
+	var removed []*Entry
+	for _, entry := range c.List() {
+		if entry.LastUsed.After(cutoff) {
+			continue
+		}
+		if err := c.Remove(entry); err != nil {
+			return removed, err
+		}
+		removed = append(removed, entry)
+	}
+	return removed, nil
+}
+
+// Clean removes every cached archive
+func (c *Cache) Clean() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, name := range []string{blobsDirName, downloadsDirName} {
+		if err := os.RemoveAll(filepath.Join(c.dir, name)); err != nil {
+			return fmt.Errorf("failed to remove cached archives: %w", err)
+		}
+	}
+	c.Entries = make(map[string]*Entry)
+	return nil
+}
+
+// Save writes the cache index to disk
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache index: %w", err)
+	}
+
+	indexPath := filepath.Join(c.dir, indexFileName)
+	tmpPath := indexPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache index: %w", err)
+	}
+	if err := os.Rename(tmpPath, indexPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace cache index: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writeArchive(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	return path
+}
+
+func TestAddAndLookup(t *testing.T) {
+	dir := t.TempDir()
+
+	c, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	entry, err := c.Add("foo", "1.0.0", writeArchive(t, "foo-1.0.0.tar.gz", "archive"))
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	if entry.Size != int64(len("archive")) || entry.Name != "foo" || entry.Version != "1.0.0" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if filepath.Base(c.Path(entry)) != "foo-1.0.0.tar.gz" ||
+		filepath.Base(filepath.Dir(c.Path(entry))) != entry.Checksum {
+		t.Errorf("blob not keyed by checksum: %s", c.Path(entry))
+	}
+
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	found, ok := reopened.Lookup("foo-1.0.0.tar.gz")
+	if !ok {
+		t.Fatalf("Lookup() did not find cached archive")
+	}
+	if found.Checksum != entry.Checksum {
+		t.Errorf("Lookup() checksum = %s, want %s", found.Checksum, entry.Checksum)
+	}
+
+	// Entries whose blob disappeared are dropped
+	os.RemoveAll(filepath.Dir(reopened.Path(found)))
+	if _, ok := reopened.Lookup("foo-1.0.0.tar.gz"); ok {
+		t.Errorf("Lookup() found archive with missing blob")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	old, _ := c.Add("foo", "1.0.0", writeArchive(t, "foo-1.0.0.tar.gz", "old"))
+	recent, _ := c.Add("foo", "1.1.0", writeArchive(t, "foo-1.1.0.tar.gz", "recent"))
+	old.LastUsed = time.Now().Add(-48 * time.Hour)
+
+	removed, err := c.Prune(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+
+	if len(removed) != 1 || removed[0].Archive != old.Archive {
+		t.Errorf("Prune() removed %v, want only %s", removed, old.Archive)
+	}
+	if _, err := os.Stat(c.Path(old)); !os.IsNotExist(err) {
+		t.Errorf("pruned blob still exists")
+	}
+	if _, ok := c.Lookup(recent.Archive); !ok {
+		t.Errorf("recently used archive was pruned")
+	}
+}
+
+func TestRemoveKeepsSharedBlobs(t *testing.T) {
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	// Identical archives under different names share a checksum directory
+	first, _ := c.Add("foo", "1.0.0", writeArchive(t, "foo-1.0.0.tar.gz", "archive"))
+	second, _ := c.Add("bar", "1.0.0", writeArchive(t, "bar-1.0.0.tar.gz", "archive"))
+
+	if err := c.Remove(first); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, ok := c.Lookup(second.Archive); !ok {
+		t.Fatalf("Remove() deleted the blob of another archive with the same content")
+	}
+
+	if err := c.Remove(second); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(c.Path(second))); !os.IsNotExist(err) {
+		t.Errorf("Remove() left an empty checksum directory behind")
+	}
+}
+
+func TestClean(t *testing.T) {
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	entry, _ := c.Add("foo", "1.0.0", writeArchive(t, "foo-1.0.0.tar.gz", "archive"))
+	if err := c.Clean(); err != nil {
+		t.Fatalf("Clean() error = %v", err)
+	}
+
+	if len(c.List()) != 0 {
+		t.Errorf("Clean() left %d entries", len(c.List()))
+	}
+	if _, err := os.Stat(c.Path(entry)); !os.IsNotExist(err) {
+		t.Errorf("Clean() left blob behind")
+	}
+}
+
+func TestConcurrentAdd(t *testing.T) {
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	archive := writeArchive(t, "foo-1.0.0.tar.gz", "archive")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entry, err := c.Add("foo", "1.0.0", archive)
+			if err != nil {
+				t.Errorf("Add() error = %v", err)
+				return
+			}
+			c.Touch(entry)
+			c.Lookup(entry.Archive)
+		}()
+	}
+	wg.Wait()
+
+	if entries := c.List(); len(entries) != 1 {
+		t.Errorf("List() = %d entries, want 1", len(entries))
+	}
+}
+
+func TestClaimDownload(t *testing.T) {
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	shared := filepath.Join(c.Dir(), downloadsDirName, "foo-1.0.0.tar.gz")
+
+	path, release, err := c.ClaimDownload("foo-1.0.0.tar.gz")
+	if err != nil {
+		t.Fatalf("ClaimDownload() error = %v", err)
+	}
+	if path != shared {
+		t.Errorf("first claim = %s, want %s", path, shared)
+	}
+
+	// A concurrent download of the same archive gets its own directory
+	private, releasePrivate, err := c.ClaimDownload("foo-1.0.0.tar.gz")
+	if err != nil {
+		t.Fatalf("ClaimDownload() error = %v", err)
+	}
+	if private == shared {
+		t.Errorf("concurrent claim got the shared path")
+	}
+	os.WriteFile(private, []byte("partial"), 0644)
+	releasePrivate()
+	if _, err := os.Stat(filepath.Dir(private)); !os.IsNotExist(err) {
+		t.Errorf("released private claim left its directory behind")
+	}
+
+	release()
+	if path, release, _ = c.ClaimDownload("foo-1.0.0.tar.gz"); path != shared {
+		t.Errorf("claim after release = %s, want %s", path, shared)
+	}
+	release()
+
+	// A claim of a process that is gone is taken over
+	claimPath := shared + claimSuffix
+	os.WriteFile(claimPath, []byte(strconv.Itoa(1<<30)), 0644)
+	if path, release, _ = c.ClaimDownload("foo-1.0.0.tar.gz"); path != shared {
+		t.Errorf("claim over a stale claim = %s, want %s", path, shared)
+	}
+	release()
+}
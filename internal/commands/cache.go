@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/rasadov/package-manager/internal/controller"
+	"github.com/rasadov/package-manager/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+func Cache() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the local download cache",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "ls",
+		Short: "List cached archives",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return controller.CacheList()
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "clean",
+		Short: "Remove all cached archives",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return controller.CacheClean()
+		},
+	})
+
+	var olderThan string
+	prune := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove cached archives that have not been used recently",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			maxAge, err := utils.ParseDuration(olderThan)
+			if err != nil {
+				return fmt.Errorf("invalid --older-than: %w", err)
+			}
+
+			return controller.CachePrune(maxAge)
+		},
+	}
+	prune.Flags().StringVar(&olderThan, "older-than", "30d", "Remove archives not used within this duration (e.g. 720h, 30d)")
+	cmd.AddCommand(prune)
+
+	return cmd
+}
@@ -6,11 +6,14 @@ import (
 
 	"github.com/rasadov/package-manager/config"
 	"github.com/rasadov/package-manager/internal/controller"
+	"github.com/rasadov/package-manager/internal/utils"
 	"github.com/spf13/cobra"
 )
 
 func Create() *cobra.Command {
 	var configPath string
+	var limitRate string
+	var opts controller.CreateOptions
 
 	cmd := &cobra.Command{
 		Use:   "create <packet.json>",
@@ -30,11 +33,21 @@ func Create() *cobra.Command {
 				return fmt.Errorf("failed to load SSH config: %w", err)
 			}
 
+			// --limit-rate overrides the limit_rate setting, 0 lifts it
+			if cmd.Flags().Changed("limit-rate") {
+				if _, err := utils.ParseRate(limitRate); err != nil {
+					return err
+				}
+				sshConfig.LimitRate = limitRate
+			}
+
 			// Create package
-			return controller.Create(packetPath, *sshConfig)
+			return controller.Create(packetPath, *sshConfig, opts)
 		},
 	}
 
 	cmd.Flags().StringVarP(&configPath, "config", "c", "ssh-config.json", "SSH configuration file path")
+	cmd.Flags().StringVar(&limitRate, "limit-rate", "", "Limit the combined transfer rate, e.g. 2MB/s")
+	cmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Overwrite a published version with different content")
 	return cmd
 }
@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"github.com/rasadov/package-manager/internal/controller"
+	"github.com/spf13/cobra"
+)
+
+func Env() *cobra.Command {
+	var prefix string
+	var packagesPath string
+
+	cmd := &cobra.Command{
+		Use:   "env",
+		Short: "Print the PATH export for installed commands",
+		Long:  "Print the PATH export for installed commands, e.g. eval \"$(pm env)\"",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Print environment
+			return controller.Env(prefix, optionalPackagesPath(cmd, packagesPath))
+		},
+	}
+
+	cmd.Flags().StringVar(&prefix, "prefix", "", "Install root (default: install_dir of the packages file or \"packages\")")
+	addPackagesFlag(cmd, &packagesPath)
+	return cmd
+}
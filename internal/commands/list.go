@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/controller"
+	"github.com/spf13/cobra"
+)
+
+func List() *cobra.Command {
+	var configPath string
+	var installed bool
+	var jsonOutput bool
+	var prefix string
+	var packagesPath string
+
+	cmd := &cobra.Command{
+		Use:   "list [name]",
+		Short: "List published or installed packages",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if installed {
+				if len(args) > 0 {
+					return fmt.Errorf("--installed does not take a package name")
+				}
+
+				// List installed packages
+				return controller.ListInstalled(prefix, optionalPackagesPath(cmd, packagesPath))
+			}
+
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+			}
+
+			// Load SSH configuration
+			sshConfig, err := config.LoadSSHConfig(configPath)
+			if err != nil {
+				return fmt.Errorf("failed to load SSH config: %w", err)
+			}
+
+			// List published packages
+			return controller.RegistryList(*sshConfig, name, jsonOutput)
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "ssh-config.json", "SSH configuration file path")
+	cmd.Flags().BoolVar(&installed, "installed", false, "List locally installed packages")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print published packages as JSON")
+	cmd.Flags().StringVar(&prefix, "prefix", "", "Install root (default: install_dir of the packages file or \"packages\")")
+	addPackagesFlag(cmd, &packagesPath)
+	return cmd
+}
@@ -0,0 +1,28 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultPackagesPath is the packages file whose install_dir is used when
+// --packages is not given and the file exists
+const defaultPackagesPath = "packages.json"
+
+// addPackagesFlag adds the --packages flag to a command working on the
+// install root
+func addPackagesFlag(cmd *cobra.Command, packagesPath *string) {
+	cmd.Flags().StringVarP(packagesPath, "packages", "p", defaultPackagesPath, "Packages file whose install_dir selects the install root")
+}
+
+// optionalPackagesPath returns the packages file to read, or an empty
+// string if the default one does not exist
+func optionalPackagesPath(cmd *cobra.Command, packagesPath string) string {
+	if !cmd.Flags().Changed("packages") {
+		if _, err := os.Stat(packagesPath); err != nil {
+			return ""
+		}
+	}
+	return packagesPath
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/controller"
+	"github.com/rasadov/package-manager/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+func Prune() *cobra.Command {
+	var configPath string
+	var prereleaseAge string
+	var opts controller.PruneOptions
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete old published versions according to retention policies",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if prereleaseAge != "" {
+				maxAge, err := utils.ParseDuration(prereleaseAge)
+				if err != nil {
+					return fmt.Errorf("invalid --prerelease-age: %w", err)
+				}
+				opts.Policy.PrereleaseAge = maxAge
+			}
+
+			// Load SSH configuration
+			sshConfig, err := config.LoadSSHConfig(configPath)
+			if err != nil {
+				return fmt.Errorf("failed to load SSH config: %w", err)
+			}
+
+			// Prune published versions
+			return controller.Prune(*sshConfig, opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "ssh-config.json", "SSH configuration file path")
+	cmd.Flags().IntVar(&opts.Policy.KeepPatches, "keep-patches", 0, "Keep the newest N patch releases of every minor version")
+	cmd.Flags().StringVar(&prereleaseAge, "prerelease-age", "", "Delete pre-releases published longer ago (e.g. 720h, 30d)")
+	cmd.Flags().StringArrayVar(&opts.Lockfiles, "lockfile", nil, "Never delete versions referenced by this lockfile (repeatable)")
+	cmd.Flags().StringVarP(&opts.Package, "package", "p", "", "Only prune versions of this package")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Report what would be deleted without deleting anything")
+	return cmd
+}
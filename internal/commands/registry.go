@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/controller"
+	"github.com/spf13/cobra"
+)
+
+func Registry() *cobra.Command {
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "registry",
+		Short: "Maintain the package registry on the server",
+	}
+	cmd.PersistentFlags().StringVarP(&configPath, "config", "c", "ssh-config.json", "SSH configuration file path")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "reindex",
+		Short: "Rebuild the package index from the published archives",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Load SSH configuration
+			sshConfig, err := config.LoadSSHConfig(configPath)
+			if err != nil {
+				return fmt.Errorf("failed to load SSH config: %w", err)
+			}
+
+			return controller.Reindex(*sshConfig)
+		},
+	})
+
+	var force bool
+	unlock := &cobra.Command{
+		Use:   "unlock",
+		Short: "Remove a registry lock left behind by an interrupted writer",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Load SSH configuration
+			sshConfig, err := config.LoadSSHConfig(configPath)
+			if err != nil {
+				return fmt.Errorf("failed to load SSH config: %w", err)
+			}
+
+			return controller.Unlock(*sshConfig, force)
+		},
+	}
+	unlock.Flags().BoolVarP(&force, "force", "f", false, "Remove the lock even if it has not expired")
+	cmd.AddCommand(unlock)
+
+	return cmd
+}
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"github.com/rasadov/package-manager/internal/controller"
+	"github.com/spf13/cobra"
+)
+
+func Rollback() *cobra.Command {
+	var opts controller.RollbackOptions
+
+	cmd := &cobra.Command{
+		Use:   "rollback <name> [version]",
+		Short: "Reinstall a previously installed version from the local store",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version := ""
+			if len(args) == 2 {
+				version = args[1]
+			}
+
+			// Roll back package
+			opts.PackagesPath = optionalPackagesPath(cmd, opts.PackagesPath)
+			return controller.Rollback(args[0], version, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Prefix, "prefix", "", "Install root (default: install_dir of the packages file or \"packages\")")
+	addPackagesFlag(cmd, &opts.PackagesPath)
+	cmd.Flags().BoolVar(&opts.AllowScripts, "allow-scripts", false, "Run the lifecycle scripts of the reinstalled version")
+	return cmd
+}
+
+func History() *cobra.Command {
+	var prefix string
+	var packagesPath string
+
+	cmd := &cobra.Command{
+		Use:   "history <name>",
+		Short: "Show the install history of a package",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Show package history
+			return controller.History(prefix, optionalPackagesPath(cmd, packagesPath), args[0])
+		},
+	}
+
+	cmd.Flags().StringVar(&prefix, "prefix", "", "Install root (default: install_dir of the packages file or \"packages\")")
+	addPackagesFlag(cmd, &packagesPath)
+	return cmd
+}
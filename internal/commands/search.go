@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/controller"
+	"github.com/spf13/cobra"
+)
+
+func Search() *cobra.Command {
+	var configPath string
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "search <term>",
+		Short: "Search published packages by name, description and keywords",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Load SSH configuration
+			sshConfig, err := config.LoadSSHConfig(configPath)
+			if err != nil {
+				return fmt.Errorf("failed to load SSH config: %w", err)
+			}
+
+			// Search packages
+			return controller.Search(*sshConfig, args[0], jsonOutput)
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "ssh-config.json", "SSH configuration file path")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print matching packages as JSON")
+	return cmd
+}
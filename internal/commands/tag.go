@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/controller"
+	"github.com/spf13/cobra"
+)
+
+func Tag() *cobra.Command {
+	var configPath string
+	var remove bool
+
+	cmd := &cobra.Command{
+		Use:   "tag <name>[@<version>] [tag]",
+		Short: "Point a distribution tag at a published version, or list tags",
+		Long: "Point a distribution tag such as stable or beta at a published version.\n" +
+			"With only a package name the tags of the package are listed, and with\n" +
+			"--delete the given tag is removed.",
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Load SSH configuration
+			sshConfig, err := config.LoadSSHConfig(configPath)
+			if err != nil {
+				return fmt.Errorf("failed to load SSH config: %w", err)
+			}
+
+			switch {
+			case len(args) == 1:
+				if remove {
+					return fmt.Errorf("--delete requires a tag")
+				}
+				return controller.ListTags(*sshConfig, args[0])
+			case remove:
+				return controller.Untag(*sshConfig, args[0], args[1])
+			default:
+				return controller.Tag(*sshConfig, args[0], args[1])
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "ssh-config.json", "SSH configuration file path")
+	cmd.Flags().BoolVar(&remove, "delete", false, "Remove the tag")
+	return cmd
+}
@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"github.com/rasadov/package-manager/internal/controller"
+	"github.com/spf13/cobra"
+)
+
+func Uninstall() *cobra.Command {
+	var opts controller.UninstallOptions
+	var packagesPath string
+
+	cmd := &cobra.Command{
+		Use:   "uninstall <name>",
+		Short: "Remove the files installed by a package",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Uninstall package
+			opts.PackagesPath = optionalPackagesPath(cmd, packagesPath)
+			return controller.Uninstall(args[0], opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Prefix, "prefix", "", "Install root (default: install_dir of the packages file or \"packages\")")
+	addPackagesFlag(cmd, &packagesPath)
+	cmd.Flags().BoolVar(&opts.Save, "save", false, "Also remove the package from the packages file")
+	cmd.Flags().BoolVar(&opts.AllowScripts, "allow-scripts", false, "Run the preuninstall script of the package")
+	cmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Remove files even if they were modified since install")
+	return cmd
+}
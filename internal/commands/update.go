@@ -6,11 +6,14 @@ import (
 
 	"github.com/rasadov/package-manager/config"
 	"github.com/rasadov/package-manager/internal/controller"
+	"github.com/rasadov/package-manager/internal/utils"
 	"github.com/spf13/cobra"
 )
 
 func Update() *cobra.Command {
 	var configPath string
+	var limitRate string
+	var opts controller.UpdateOptions
 
 	cmd := &cobra.Command{
 		Use:   "update <packages.json>",
@@ -30,11 +33,26 @@ func Update() *cobra.Command {
 				return fmt.Errorf("failed to load SSH config: %w", err)
 			}
 
+			// --limit-rate overrides the limit_rate setting, 0 lifts it
+			if cmd.Flags().Changed("limit-rate") {
+				if _, err := utils.ParseRate(limitRate); err != nil {
+					return err
+				}
+				sshConfig.LimitRate = limitRate
+			}
+
 			// Update packages
-			return controller.Update(packagesPath, *sshConfig)
+			return controller.Update(packagesPath, *sshConfig, opts)
 		},
 	}
 
 	cmd.Flags().StringVarP(&configPath, "config", "c", "ssh-config.json", "SSH configuration file path")
+	cmd.Flags().StringVar(&limitRate, "limit-rate", "", "Limit the combined transfer rate, e.g. 2MB/s")
+	cmd.Flags().BoolVar(&opts.AllowScripts, "allow-scripts", false, "Run the lifecycle scripts of all packages")
+	cmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Reinstall packages that are already up to date")
+	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", controller.DefaultJobs, "Number of packages to download in parallel")
+	cmd.Flags().BoolVar(&opts.Offline, "offline", false, "Install only from the local cache and lockfile")
+	cmd.Flags().StringVar(&opts.Channel, "channel", "", "Resolve packages without a tag through this distribution tag, e.g. beta")
+	cmd.Flags().StringVar(&opts.Prefix, "prefix", "", "Install root (default: install_dir of the packages file or \"packages\")")
 	return cmd
 }
@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"github.com/rasadov/package-manager/internal/controller"
+	"github.com/spf13/cobra"
+)
+
+func Verify() *cobra.Command {
+	var prefix string
+	var packagesPath string
+
+	cmd := &cobra.Command{
+		Use:   "verify [name]",
+		Short: "Check installed files and list pending config defaults",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+			}
+
+			// Verify installed files
+			return controller.Verify(prefix, optionalPackagesPath(cmd, packagesPath), name)
+		},
+	}
+
+	cmd.Flags().StringVar(&prefix, "prefix", "", "Install root (default: install_dir of the packages file or \"packages\")")
+	addPackagesFlag(cmd, &packagesPath)
+	return cmd
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/controller"
+	"github.com/spf13/cobra"
+)
+
+func Yank() *cobra.Command {
+	var configPath string
+	var undo bool
+
+	cmd := &cobra.Command{
+		Use:   "yank <name>@<version>",
+		Short: "Stop a published version from being selected for new installs",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Load SSH configuration
+			sshConfig, err := config.LoadSSHConfig(configPath)
+			if err != nil {
+				return fmt.Errorf("failed to load SSH config: %w", err)
+			}
+
+			// Yank version
+			return controller.Yank(*sshConfig, args[0], undo)
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "ssh-config.json", "SSH configuration file path")
+	cmd.Flags().BoolVar(&undo, "undo", false, "Make a yanked version selectable again")
+	return cmd
+}
+
+func Delete() *cobra.Command {
+	var configPath string
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "delete <name>@<version>",
+		Short: "Remove a published version from the server",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !yes {
+				fmt.Fprintf(cmd.OutOrStdout(), "Delete %s from the server? This cannot be undone [y/N] ", args[0])
+				answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+				answer = strings.ToLower(strings.TrimSpace(answer))
+				if answer != "y" && answer != "yes" {
+					return fmt.Errorf("aborted")
+				}
+			}
+
+			// Load SSH configuration
+			sshConfig, err := config.LoadSSHConfig(configPath)
+			if err != nil {
+				return fmt.Errorf("failed to load SSH config: %w", err)
+			}
+
+			// Delete version
+			return controller.Delete(*sshConfig, args[0])
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "ssh-config.json", "SSH configuration file path")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Delete without asking for confirmation")
+	return cmd
+}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rasadov/package-manager/internal/state"
+)
+
+// binDirName is the directory inside the install root holding command links.
+// It lives in the state directory, so packages installed into a bin
+// directory of the install root never share it with the links.
+const binDirName = state.DirName + "/bin"
+
+// binPaths returns the root-relative link paths of the commands declared
+// in a package manifest
+func binPaths(bin map[string]string) ([]string, error) {
+	paths := make([]string, 0, len(bin))
+	for name := range bin {
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("invalid command name: %q", name)
+		}
+		paths = append(paths, binDirName+"/"+name)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// linkBins links every command declared by a package into the shared bin
+// directory as part of tx, making the targets executable. Links of commands
+// the previous version declared but this one does not are removed. It
+// returns the root-relative paths of the links.
+func linkBins(root string, tx *transaction, installDir string, bin map[string]string, previous *state.Package) ([]string, error) {
+	paths, err := binPaths(bin)
+	if err != nil {
+		return nil, err
+	}
+
+	binDir := filepath.Join(root, binDirName)
+	for _, linkPath := range paths {
+		name := filepath.Base(linkPath)
+		target := filepath.Join(installDir, filepath.FromSlash(bin[name]))
+		if !isWithin(target, installDir) {
+			return nil, fmt.Errorf("command %s points outside of the package: %s", name, bin[name])
+		}
+
+		info, err := os.Stat(target)
+		if err != nil {
+			return nil, fmt.Errorf("command %s points to a missing file: %s", name, bin[name])
+		}
+		if err := os.Chmod(target, info.Mode()|0111); err != nil {
+			return nil, fmt.Errorf("failed to make %s executable: %w", bin[name], err)
+		}
+
+		if err := os.MkdirAll(binDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create bin directory: %w", err)
+		}
+		relTarget, err := filepath.Rel(binDir, target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve command %s: %w", name, err)
+		}
+
+		// Create the link next to its final place and swap it in
+		link := filepath.Join(binDir, name)
+		staged := siblingPath(link, "new")
+		if err := os.Symlink(relTarget, staged); err != nil {
+			return nil, fmt.Errorf("failed to link command %s: %w", name, err)
+		}
+		if err := tx.swapIn(staged, link); err != nil {
+			os.Remove(staged)
+			return nil, err
+		}
+	}
+
+	if previous != nil {
+		linked := make(map[string]bool, len(paths))
+		for _, path := range paths {
+			linked[path] = true
+		}
+		for _, path := range previous.Bins {
+			if linked[path] {
+				continue
+			}
+			link := filepath.Join(root, filepath.FromSlash(path))
+			if _, err := os.Lstat(link); err != nil {
+				continue
+			}
+			if err := tx.moveAside(link); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// removeBins deletes the command links of a package
+func removeBins(root string, paths []string) error {
+	for _, path := range paths {
+		link := filepath.Join(root, filepath.FromSlash(path))
+		info, err := os.Lstat(link)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		if err := os.Remove(link); err != nil {
+			return fmt.Errorf("failed to remove command link %s: %w", path, err)
+		}
+	}
+	pruneEmptyDirs(root, filepath.Join(root, binDirName))
+	return nil
+}
+
+// Env prints the shell commands that put the command links of the install
+// root selected by prefix or the packages file at packagesPath on the PATH
+func Env(prefix, packagesPath string) error {
+	root, err := loadInstallRoot(prefix, packagesPath)
+	if err != nil {
+		return err
+	}
+	binDir, err := filepath.Abs(filepath.Join(root, binDirName))
+	if err != nil {
+		return fmt.Errorf("failed to resolve bin directory: %w", err)
+	}
+
+	fmt.Printf("export PATH=\"%s%c$PATH\"\n", binDir, os.PathListSeparator)
+	return nil
+}
@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rasadov/package-manager/internal/state"
+)
+
+func TestBinPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		bin     map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{"none", nil, []string{}, false},
+		{"sorted", map[string]string{"tool": "build/tool", "aux": "aux.sh"}, []string{".pm/bin/aux", ".pm/bin/tool"}, false},
+		{"slash", map[string]string{"sub/tool": "tool"}, nil, true},
+		{"dotdot", map[string]string{"..": "tool"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := binPaths(tt.bin)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("binPaths() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("binPaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstallArchiveLinksBins(t *testing.T) {
+	root := t.TempDir()
+	archives := t.TempDir()
+
+	db, err := state.Open(root)
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	v1 := filepath.Join(archives, "foo-1.0.0.tar.gz")
+	writeTestArchive(t, v1, map[string]string{
+		".pm/packet.json": `{"name": "foo", "ver": "1.0.0", "bin": {"foo": "build/foo", "foo-old": "old.sh"}}`,
+		"build/foo":       "v1",
+		"old.sh":          "old",
+	})
+
+	tx := &transaction{}
+	if err := installArchive(db, tx, "foo", "1.0.0", "", v1, false); err != nil {
+		t.Fatalf("installArchive(1.0.0) error = %v", err)
+	}
+	tx.commit()
+
+	link := filepath.Join(root, binDirName, "foo")
+	if got := readFileString(t, link); got != "v1" {
+		t.Errorf("bin/foo = %q, want %q", got, "v1")
+	}
+	info, err := os.Stat(link)
+	if err != nil {
+		t.Fatalf("stat bin/foo: %v", err)
+	}
+	if info.Mode()&0111 == 0 {
+		t.Errorf("bin/foo target is not executable: %v", info.Mode())
+	}
+
+	// The upgrade drops foo-old and relinks foo
+	v2 := filepath.Join(archives, "foo-2.0.0.tar.gz")
+	writeTestArchive(t, v2, map[string]string{
+		".pm/packet.json": `{"name": "foo", "ver": "2.0.0", "bin": {"foo": "build/foo"}}`,
+		"build/foo":       "v2",
+	})
+	if err := installArchive(db, tx, "foo", "2.0.0", "", v2, false); err != nil {
+		t.Fatalf("installArchive(2.0.0) error = %v", err)
+	}
+	tx.commit()
+
+	if got := readFileString(t, link); got != "v2" {
+		t.Errorf("bin/foo = %q, want %q", got, "v2")
+	}
+	if _, err := os.Lstat(filepath.Join(root, binDirName, "foo-old")); !os.IsNotExist(err) {
+		t.Errorf("bin/foo-old still exists after upgrade")
+	}
+	pkg, _ := db.Get("foo")
+	if !reflect.DeepEqual(pkg.Bins, []string{".pm/bin/foo"}) {
+		t.Errorf("Bins = %v, want [.pm/bin/foo]", pkg.Bins)
+	}
+
+	if err := removeBins(root, pkg.Bins); err != nil {
+		t.Fatalf("removeBins() error = %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, binDirName)); !os.IsNotExist(err) {
+		t.Errorf("bin directory still exists after removing the last link")
+	}
+}
+
+func TestInstallArchiveBinPackage(t *testing.T) {
+	root := t.TempDir()
+	archives := t.TempDir()
+
+	db, err := state.Open(root)
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	foo := filepath.Join(archives, "foo-1.0.0.tar.gz")
+	writeTestArchive(t, foo, map[string]string{
+		".pm/packet.json": `{"name": "foo", "ver": "1.0.0", "bin": {"foo": "foo.sh"}}`,
+		"foo.sh":          "foo",
+	})
+	tx := &transaction{}
+	if err := installArchive(db, tx, "foo", "1.0.0", "", foo, false); err != nil {
+		t.Fatalf("installArchive(foo) error = %v", err)
+	}
+	tx.commit()
+
+	// A package named bin owns <root>/bin without touching the links
+	bin := filepath.Join(archives, "bin-1.0.0.tar.gz")
+	writeTestArchive(t, bin, map[string]string{"tool": "bin tool"})
+	tx = &transaction{}
+	if err := installArchive(db, tx, "bin", "1.0.0", "", bin, false); err != nil {
+		t.Fatalf("installArchive(bin) error = %v", err)
+	}
+	tx.commit()
+
+	if got := readFileString(t, filepath.Join(root, "bin", "tool")); got != "bin tool" {
+		t.Errorf("bin/tool = %q, want %q", got, "bin tool")
+	}
+	if got := readFileString(t, filepath.Join(root, binDirName, "foo")); got != "foo" {
+		t.Errorf("link foo = %q, want %q", got, "foo")
+	}
+}
+
+func TestInstallArchiveMissingBinTarget(t *testing.T) {
+	root := t.TempDir()
+
+	db, err := state.Open(root)
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	archive := filepath.Join(t.TempDir(), "foo-1.0.0.tar.gz")
+	writeTestArchive(t, archive, map[string]string{
+		".pm/packet.json": `{"name": "foo", "ver": "1.0.0", "bin": {"foo": "missing"}}`,
+		"foo.txt":         "foo",
+	})
+
+	tx := &transaction{}
+	if err := installArchive(db, tx, "foo", "1.0.0", "", archive, false); err == nil {
+		t.Fatalf("installArchive() expected error for missing command target")
+	}
+	if err := tx.rollback(); err != nil {
+		t.Fatalf("rollback() error = %v", err)
+	}
+	if _, ok := db.Get("foo"); ok {
+		t.Errorf("package recorded after failed install")
+	}
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rasadov/package-manager/internal/progress"
+)
+
+// CacheList prints the archives in the local download cache
+func CacheList() error {
+	archiveCache, err := openCache()
+	if err != nil {
+		return fmt.Errorf("failed to open download cache: %w", err)
+	}
+
+	entries := archiveCache.List()
+	if len(entries) == 0 {
+		fmt.Printf("Cache %s is empty\n", archiveCache.Dir())
+		return nil
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ARCHIVE\tSIZE\tLAST USED\tSHA256")
+	for _, entry := range entries {
+		total += entry.Size
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			entry.Archive, progress.FormatSize(entry.Size), entry.LastUsed.Local().Format("2006-01-02 15:04"), shortChecksum(entry.Checksum))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d archive(s), %s in %s\n", len(entries), progress.FormatSize(total), archiveCache.Dir())
+	return nil
+}
+
+// shortChecksum abbreviates a checksum for display
+func shortChecksum(checksum string) string {
+	if len(checksum) > 12 {
+		return checksum[:12]
+	}
+	return checksum
+}
+
+// CacheClean removes every archive from the local download cache
+func CacheClean() error {
+	archiveCache, err := openCache()
+	if err != nil {
+		return fmt.Errorf("failed to open download cache: %w", err)
+	}
+
+	count := len(archiveCache.Entries)
+	if err := archiveCache.Clean(); err != nil {
+		return err
+	}
+	if err := archiveCache.Save(); err != nil {
+		return fmt.Errorf("failed to save download cache: %w", err)
+	}
+
+	fmt.Printf("Removed %d cached archive(s)\n", count)
+	return nil
+}
+
+// CachePrune removes archives not used within maxAge from the local download cache
+func CachePrune(maxAge time.Duration) error {
+	archiveCache, err := openCache()
+	if err != nil {
+		return fmt.Errorf("failed to open download cache: %w", err)
+	}
+
+	removed, err := archiveCache.Prune(maxAge)
+	for _, entry := range removed {
+		fmt.Printf("Removed %s\n", entry.Archive)
+	}
+	if err != nil {
+		return err
+	}
+	if err := archiveCache.Save(); err != nil {
+		return fmt.Errorf("failed to save download cache: %w", err)
+	}
+
+	fmt.Printf("Removed %d cached archive(s)\n", len(removed))
+	return nil
+}
@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/rasadov/package-manager/internal/state"
+	"github.com/rasadov/package-manager/internal/utils"
+)
+
+// pmnewSuffix is appended to the new default of a locally edited config file
+const pmnewSuffix = ".pmnew"
+
+// keepConfigFiles marks the config files of a package in record and keeps
+// the ones edited since the previous install. The edited copy replaces the
+// default in staging, and the default is staged next to it with the .pmnew
+// suffix. It returns the root-relative paths of the staged defaults.
+func keepConfigFiles(root string, configFiles []string, previous, record *state.Package, installDir, staging string) ([]string, error) {
+	if len(configFiles) == 0 {
+		return nil, nil
+	}
+
+	relInstallDir, err := filepath.Rel(root, installDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve install directory: %w", err)
+	}
+
+	files := make(map[string]*state.File, len(record.Files))
+	for i := range record.Files {
+		files[record.Files[i].Path] = &record.Files[i]
+	}
+	installed := make(map[string]string)
+	if previous != nil {
+		for _, file := range previous.Files {
+			installed[file.Path] = file.Checksum
+		}
+	}
+
+	var pending []string
+	for _, configFile := range configFiles {
+		relPath := filepath.ToSlash(filepath.Join(relInstallDir, filepath.FromSlash(configFile)))
+		file, ok := files[relPath]
+		if !ok {
+			return nil, fmt.Errorf("config file %s is not part of the package", configFile)
+		}
+		file.Config = true
+
+		target := filepath.Join(root, filepath.FromSlash(relPath))
+		current, err := utils.FileChecksum(target)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to checksum %s: %w", relPath, err)
+		}
+
+		// Unedited files and files already matching the new default are replaced
+		if current == installed[relPath] || current == file.Checksum {
+			continue
+		}
+
+		staged := filepath.Join(staging, filepath.FromSlash(configFile))
+		if err := os.Rename(staged, staged+pmnewSuffix); err != nil {
+			return nil, fmt.Errorf("failed to stage new default of %s: %w", relPath, err)
+		}
+		if err := utils.CopyFile(target, staged); err != nil {
+			return nil, fmt.Errorf("failed to keep %s: %w", relPath, err)
+		}
+
+		fmt.Printf("Keeping modified %s, new default written to %s%s\n", relPath, relPath, pmnewSuffix)
+		pending = append(pending, relPath+pmnewSuffix)
+	}
+
+	return pending, nil
+}
+
+// trackPending returns the staged defaults to record for a package: the
+// ones staged by this install and those of earlier installs still on disk
+func trackPending(root string, previous *state.Package, staged []string) []string {
+	pending := append([]string(nil), staged...)
+	if previous == nil {
+		return pending
+	}
+
+	seen := make(map[string]bool, len(staged))
+	for _, path := range staged {
+		seen[path] = true
+	}
+	for _, path := range previous.Pending {
+		if seen[path] {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(path))); err == nil {
+			pending = append(pending, path)
+		}
+	}
+	return pending
+}
+
+// removePending deletes the staged defaults of a package
+func removePending(root string, paths []string) error {
+	for _, path := range paths {
+		if err := os.Remove(filepath.Join(root, filepath.FromSlash(path))); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// verifyResult is the state of an installed file that differs from its package
+type verifyResult struct {
+	Package string
+	Path    string
+	Status  string
+	// Config is set for config files, which may be edited locally
+	Config bool
+}
+
+// Verify statuses
+const (
+	statusMissing  = "missing"
+	statusModified = "modified"
+	statusPending  = "pending"
+)
+
+// verifyPackage compares the installed files of a package with their
+// recorded checksums. Edited config files are reported as modified and
+// their staged defaults as pending.
+func verifyPackage(root string, pkg *state.Package) ([]verifyResult, error) {
+	var results []verifyResult
+	for _, file := range pkg.Files {
+		filePath := filepath.Join(root, filepath.FromSlash(file.Path))
+
+		checksum, err := utils.FileChecksum(filePath)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("failed to checksum %s: %w", file.Path, err)
+			}
+			results = append(results, verifyResult{pkg.Name, file.Path, statusMissing, file.Config})
+		} else if checksum != file.Checksum {
+			results = append(results, verifyResult{pkg.Name, file.Path, statusModified, file.Config})
+		}
+
+		if file.Config {
+			if _, err := os.Stat(filePath + pmnewSuffix); err == nil {
+				results = append(results, verifyResult{pkg.Name, file.Path + pmnewSuffix, statusPending, true})
+			}
+		}
+	}
+	return results, nil
+}
+
+// Verify checks the files of the installed packages, or only of the named
+// package, in the install root selected by prefix or the packages file at
+// packagesPath. It fails if files that are not config files were modified
+// or removed.
+func Verify(prefix, packagesPath, name string) error {
+	root, err := loadInstallRoot(prefix, packagesPath)
+	if err != nil {
+		return err
+	}
+	db, err := state.Load(root)
+	if err != nil {
+		return fmt.Errorf("failed to load state database: %w", err)
+	}
+
+	packages := db.List()
+	if name != "" {
+		pkg, ok := db.Get(name)
+		if !ok {
+			return fmt.Errorf("package %s is not installed", name)
+		}
+		packages = []*state.Package{pkg}
+	}
+
+	var results []verifyResult
+	for _, pkg := range packages {
+		pkgResults, err := verifyPackage(db.Root(), pkg)
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %w", pkg.Name, err)
+		}
+		results = append(results, pkgResults...)
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("Verified %d package(s), no changes found\n", len(packages))
+		return nil
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tSTATUS\tPATH")
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result.Package, result.Status, result.Path)
+		if !result.Config {
+			failed++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d installed file(s) were modified or removed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/rasadov/package-manager/internal/state"
+)
+
+func TestInstallArchiveKeepsEditedConfigFiles(t *testing.T) {
+	for _, dest := range []string{"", "shared"} {
+		t.Run("dest="+dest, func(t *testing.T) {
+			root := t.TempDir()
+			archives := t.TempDir()
+
+			db, err := state.Open(root)
+			if err != nil {
+				t.Fatalf("state.Open() error = %v", err)
+			}
+			defer db.Close()
+
+			installDir := dest
+			if installDir == "" {
+				installDir = "foo"
+			}
+			manifest := `{"name": "foo", "config_files": ["foo.conf", "other.conf"]}`
+
+			v1 := filepath.Join(archives, "foo-1.0.0.tar.gz")
+			writeTestArchive(t, v1, map[string]string{
+				".pm/packet.json": manifest,
+				"foo.conf":        "default 1",
+				"other.conf":      "other 1",
+			})
+			tx := &transaction{}
+			if err := installArchive(db, tx, "foo", "1.0.0", dest, v1, false); err != nil {
+				t.Fatalf("installArchive(1.0.0) error = %v", err)
+			}
+			tx.commit()
+
+			confPath := filepath.Join(root, installDir, "foo.conf")
+			if err := os.WriteFile(confPath, []byte("edited"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			v2 := filepath.Join(archives, "foo-2.0.0.tar.gz")
+			writeTestArchive(t, v2, map[string]string{
+				".pm/packet.json": manifest,
+				"foo.conf":        "default 2",
+				"other.conf":      "other 2",
+			})
+			if err := installArchive(db, tx, "foo", "2.0.0", dest, v2, false); err != nil {
+				t.Fatalf("installArchive(2.0.0) error = %v", err)
+			}
+			tx.commit()
+
+			if got := readFileString(t, confPath); got != "edited" {
+				t.Errorf("foo.conf = %q, want the edited copy", got)
+			}
+			if got := readFileString(t, confPath+pmnewSuffix); got != "default 2" {
+				t.Errorf("foo.conf.pmnew = %q, want the new default", got)
+			}
+			// Unedited config files are upgraded
+			if got := readFileString(t, filepath.Join(root, installDir, "other.conf")); got != "other 2" {
+				t.Errorf("other.conf = %q, want %q", got, "other 2")
+			}
+			if _, err := os.Stat(filepath.Join(root, installDir, "other.conf"+pmnewSuffix)); !os.IsNotExist(err) {
+				t.Errorf("other.conf.pmnew written for an unedited config file")
+			}
+
+			pkg, _ := db.Get("foo")
+			results, err := verifyPackage(root, pkg)
+			if err != nil {
+				t.Fatalf("verifyPackage() error = %v", err)
+			}
+			confFile := installDir + "/foo.conf"
+			expected := []verifyResult{
+				{"foo", confFile, statusModified, true},
+				{"foo", confFile + pmnewSuffix, statusPending, true},
+			}
+			if !reflect.DeepEqual(results, expected) {
+				t.Errorf("verifyPackage() = %v, want %v", results, expected)
+			}
+
+			// Uninstalling removes the staged default with the package
+			if !reflect.DeepEqual(pkg.Pending, []string{confFile + pmnewSuffix}) {
+				t.Errorf("Pending = %v, want [%s]", pkg.Pending, confFile+pmnewSuffix)
+			}
+			if err := db.Save(); err != nil {
+				t.Fatalf("db.Save() error = %v", err)
+			}
+			db.Close()
+			if err := Uninstall("foo", UninstallOptions{Prefix: root, Force: true}); err != nil {
+				t.Fatalf("Uninstall() error = %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(root, installDir)); !os.IsNotExist(err) {
+				t.Errorf("%s still exists after uninstall", installDir)
+			}
+		})
+	}
+}
+
+func TestKeepConfigFilesUnknownPath(t *testing.T) {
+	root := t.TempDir()
+	record := &state.Package{Files: []state.File{{Path: "foo/foo.conf"}}}
+
+	_, err := keepConfigFiles(root, []string{"missing.conf"}, nil, record, filepath.Join(root, "foo"), t.TempDir())
+	if err == nil {
+		t.Errorf("keepConfigFiles() expected error for a path outside the package")
+	}
+}
+
+func TestVerifyPackage(t *testing.T) {
+	root := t.TempDir()
+	files := writeInstalledFiles(t, root, map[string]string{
+		"foo/a.txt": "a",
+		"foo/b.txt": "b",
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	pkg := &state.Package{Name: "foo", Files: append(files, state.File{Path: "foo/c.txt", Checksum: files[0].Checksum})}
+
+	if err := os.WriteFile(filepath.Join(root, "foo/b.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := verifyPackage(root, pkg)
+	if err != nil {
+		t.Fatalf("verifyPackage() error = %v", err)
+	}
+	expected := []verifyResult{
+		{"foo", "foo/b.txt", statusModified, false},
+		{"foo", "foo/c.txt", statusMissing, false},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("verifyPackage() = %v, want %v", results, expected)
+	}
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/state"
+	"github.com/rasadov/package-manager/internal/utils"
+)
+
+// readManifest returns the packet manifest embedded in a package archive,
+// or nil for archives created without one
+func readManifest(archivePath string) (*config.PacketConfig, error) {
+	data, err := utils.ReadTarGzFile(archivePath, manifestEntry)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest config.PacketConfig
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// checkConflicts compares the files a package is about to install against
+// the files owned by other installed packages. Files owned by a package
+// listed in replaces are taken over and returned grouped by their current
+// owner; any other overlap is reported as an error naming both owners.
+func checkConflicts(db *state.DB, name string, paths []string, replaces []string) (map[string][]string, error) {
+	replaceable := make(map[string]bool, len(replaces))
+	for _, pkg := range replaces {
+		replaceable[pkg] = true
+	}
+
+	owners := db.FileOwners()
+	takeover := make(map[string][]string)
+	var conflicts []string
+
+	for _, path := range paths {
+		owner, ok := owners[path]
+		if !ok || owner == name {
+			continue
+		}
+		if replaceable[owner] {
+			takeover[owner] = append(takeover[owner], path)
+			continue
+		}
+		conflicts = append(conflicts, fmt.Sprintf("%s is owned by %s and also provided by %s", path, owner, name))
+	}
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, fmt.Errorf("%d file conflict(s): %s", len(conflicts), strings.Join(conflicts, "; "))
+	}
+
+	return takeover, nil
+}
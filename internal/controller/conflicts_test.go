@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rasadov/package-manager/internal/state"
+)
+
+func TestCheckConflicts(t *testing.T) {
+	db, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	db.Put(&state.Package{Name: "bar", Files: []state.File{{Path: "bin/tool"}, {Path: "bin/bar"}}})
+	db.Put(&state.Package{Name: "foo", Files: []state.File{{Path: "bin/foo"}}})
+
+	// Files the package already owns are not conflicts
+	takeover, err := checkConflicts(db, "foo", []string{"bin/foo", "bin/new"}, nil)
+	if err != nil || len(takeover) != 0 {
+		t.Errorf("checkConflicts() = %v, %v, want no conflicts", takeover, err)
+	}
+
+	_, err = checkConflicts(db, "foo", []string{"bin/foo", "bin/tool"}, nil)
+	if err == nil {
+		t.Fatalf("checkConflicts() expected conflict error")
+	}
+	if !strings.Contains(err.Error(), "bin/tool is owned by bar and also provided by foo") {
+		t.Errorf("conflict error does not name both owners: %v", err)
+	}
+
+	takeover, err = checkConflicts(db, "foo", []string{"bin/foo", "bin/tool"}, []string{"bar"})
+	if err != nil {
+		t.Fatalf("checkConflicts() with replaces error = %v", err)
+	}
+	expected := map[string][]string{"bar": {"bin/tool"}}
+	if !reflect.DeepEqual(takeover, expected) {
+		t.Errorf("checkConflicts() takeover = %v, want %v", takeover, expected)
+	}
+}
+
+func TestInstallArchiveConflicts(t *testing.T) {
+	root := t.TempDir()
+	archives := t.TempDir()
+
+	db, err := state.Open(root)
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	bar := filepath.Join(archives, "bar-1.0.0.tar.gz")
+	writeTestArchive(t, bar, map[string]string{"tool": "bar tool", "bar": "bar"})
+
+	tx := &transaction{}
+	if err := installArchive(db, tx, "bar", "1.0.0", "bin", bar, false); err != nil {
+		t.Fatalf("installArchive(bar) error = %v", err)
+	}
+	tx.commit()
+
+	foo := filepath.Join(archives, "foo-1.0.0.tar.gz")
+	writeTestArchive(t, foo, map[string]string{"tool": "foo tool"})
+
+	if err := installArchive(db, tx, "foo", "1.0.0", "bin", foo, false); err == nil {
+		t.Fatalf("installArchive(foo) expected conflict error")
+	}
+	if got := readFileString(t, filepath.Join(root, "bin/tool")); got != "bar tool" {
+		t.Errorf("conflicting install modified bin/tool: %q", got)
+	}
+
+	// A package replacing bar may take its files over
+	fooReplaces := filepath.Join(archives, "foo-1.1.0.tar.gz")
+	writeTestArchive(t, fooReplaces, map[string]string{
+		".pm/packet.json": `{"name": "foo", "ver": "1.1.0", "replaces": ["bar"]}`,
+		"tool":            "foo tool",
+	})
+
+	if err := installArchive(db, tx, "foo", "1.1.0", "bin", fooReplaces, false); err != nil {
+		t.Fatalf("installArchive(foo with replaces) error = %v", err)
+	}
+	tx.commit()
+
+	if got := readFileString(t, filepath.Join(root, "bin/tool")); got != "foo tool" {
+		t.Errorf("bin/tool = %q, want %q", got, "foo tool")
+	}
+	owners := db.FileOwners()
+	if owners["bin/tool"] != "foo" || owners["bin/bar"] != "bar" {
+		t.Errorf("unexpected owners after takeover: %v", owners)
+	}
+
+	// Uninstalling bar leaves the taken over file alone
+	barRecord, _ := db.Get("bar")
+	if _, err := removeInstalledFiles(root, barRecord.Files, false); err != nil {
+		t.Fatalf("removeInstalledFiles() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "bin/tool")); err != nil {
+		t.Errorf("taken over file removed with its previous owner: %v", err)
+	}
+}
@@ -4,14 +4,22 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/progress"
 	"github.com/rasadov/package-manager/internal/ssh"
 	"github.com/rasadov/package-manager/internal/utils"
 )
 
+// CreateOptions controls how packages are published
+type CreateOptions struct {
+	// Force overwrites a published version with different content
+	Force bool
+}
+
 // Create creates a package from the packet configuration
-func Create(packetPath string, sshConfig config.SSHConfig) error {
+func Create(packetPath string, sshConfig config.SSHConfig, opts CreateOptions) error {
 	// Load packet configuration
 	packetConfig, err := config.LoadPacketConfig(packetPath)
 	if err != nil {
@@ -51,7 +59,13 @@ func Create(packetPath string, sshConfig config.SSHConfig) error {
 		fmt.Printf("  Exclude patterns: %v\n", allExcludePatterns)
 	}
 
-	if err := utils.CreateTarGz(allIncludePatterns, allExcludePatterns, archivePath); err != nil {
+	// Embed the manifest and lifecycle scripts
+	metadata, err := packageMetadata(packetConfig)
+	if err != nil {
+		return fmt.Errorf("failed to prepare package metadata: %w", err)
+	}
+
+	if err := utils.CreateTarGzWithEntries(allIncludePatterns, allExcludePatterns, metadata, archivePath); err != nil {
 		return fmt.Errorf("failed to create archive: %w", err)
 	}
 
@@ -68,14 +82,124 @@ func Create(packetPath string, sshConfig config.SSHConfig) error {
 		return fmt.Errorf("failed to create remote directory: %w", err)
 	}
 
-	// Upload archive
+	entry, err := newIndexEntry(packetConfig, archivePath)
+	if err != nil {
+		return err
+	}
+
+	// Hold the registry lock during the upload and index update so
+	// concurrent publishers do not interleave
+	lock, err := lockRegistry(sshClient)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	// Published versions are immutable: identical content needs no upload
+	// and different content is only accepted with --force
 	remotePath := filepath.Join(remoteDir, archiveName)
+	published, indexed, err := publishedChecksum(sshClient, packetConfig.Name, archiveName)
+	if err != nil {
+		return err
+	}
+	if published == entry.Checksum {
+		fmt.Printf("%s is already published with identical content, nothing to upload\n", archiveName)
+		if indexed {
+			return nil
+		}
+		// An upload interrupted before the index update left the archive
+		// unlisted
+		fmt.Println("Adding it to the package index...")
+		return modifyIndex(sshClient, lock, func(idx *RegistryIndex) {
+			idx.put(packetConfig.Name, entry)
+		})
+	}
+	if published != "" {
+		if !opts.Force {
+			return fmt.Errorf("%s %s is already published with different content (publish a new version, or use --force to overwrite it)", packetConfig.Name, packetConfig.Version)
+		}
+		fmt.Printf("Warning: overwriting published %s with different content\n", archiveName)
+	}
+
+	// Upload archive
 	fmt.Printf("Uploading to %s...\n", remotePath)
 
-	if err := sshClient.UploadFile(archivePath, remotePath); err != nil {
+	bar := progress.NewBoard(os.Stdout).Start(archiveName, entry.Size)
+	err = sshClient.UploadFile(archivePath, remotePath, lock.RefreshDuring(bar.Update))
+	summary := bar.Finish()
+	if err != nil {
 		return fmt.Errorf("failed to upload archive: %w", err)
 	}
+	fmt.Printf("Uploaded %s: %s\n", archiveName, summary)
+
+	// Make sure the lock survived the upload before touching the index
+	if err := lock.Refresh(0); err != nil {
+		return fmt.Errorf("failed to refresh registry lock: %w", err)
+	}
+
+	// Add the archive to the package index
+	fmt.Println("Updating package index...")
+	if err := modifyIndex(sshClient, lock, func(idx *RegistryIndex) {
+		idx.put(packetConfig.Name, entry)
+	}); err != nil {
+		return err
+	}
+
+	if published != "" {
+		meta, err := loadPackageMeta(sshClient, packetConfig.Name)
+		if err != nil {
+			return err
+		}
+		meta.addEvent(actionOverwrite, packetConfig.Version, sshConfig.Username)
+		if err := savePackageMeta(sshClient, packetConfig.Name, meta); err != nil {
+			return err
+		}
+	}
 
 	fmt.Printf("Package %s successfully created and uploaded!\n", packetConfig.Name)
 	return nil
 }
+
+// publishedChecksum returns the checksum of a published archive, or an
+// empty string if it is not published, and whether the package index lists
+// it. The index is consulted before reading the archive itself.
+func publishedChecksum(sshClient *ssh.Client, name, archiveName string) (string, bool, error) {
+	remotePath := filepath.Join(sshClient.GetRemoteDir(), archiveName)
+	exists, err := sshClient.FileExists(remotePath)
+	if err != nil || !exists {
+		return "", false, err
+	}
+
+	idx, err := loadIndex(sshClient)
+	if err != nil {
+		return "", false, err
+	}
+	if idx != nil {
+		if entry, ok := idx.find(name, archiveName); ok {
+			return entry.Checksum, true, nil
+		}
+	}
+	checksum, err := sshClient.FileChecksum(remotePath)
+	return checksum, false, err
+}
+
+// newIndexEntry describes a locally built archive for the package index
+func newIndexEntry(packetConfig *config.PacketConfig, archivePath string) (IndexEntry, error) {
+	checksum, err := utils.FileChecksum(archivePath)
+	if err != nil {
+		return IndexEntry{}, fmt.Errorf("failed to checksum archive: %w", err)
+	}
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return IndexEntry{}, fmt.Errorf("failed to stat archive: %w", err)
+	}
+
+	return IndexEntry{
+		Version:      packetConfig.Version,
+		Archive:      filepath.Base(archivePath),
+		Size:         info.Size(),
+		Checksum:     checksum,
+		Dependencies: packetConfig.Dependencies,
+		Published:    time.Now().UTC(),
+	}, nil
+}
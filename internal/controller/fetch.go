@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/cache"
+	"github.com/rasadov/package-manager/internal/progress"
+	"github.com/rasadov/package-manager/internal/ssh"
+	"github.com/rasadov/package-manager/internal/utils"
+)
+
+// openCache opens the local download cache
+func openCache() (*cache.Cache, error) {
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return cache.Open(dir)
+}
+
+// fetchArchive returns the cache entry of a package archive, downloading
+// the archive into the cache if it is missing or outdated. A non-empty
+// checksum, as listed in the package index, is what the archive must match.
+// Messages are written to out and the download is shown on board. The
+// returned summary is nil if the cached archive was used.
+func fetchArchive(sshClient *ssh.Client, archiveCache *cache.Cache, board *progress.Board, name, version, archiveName, checksum string, out io.Writer) (*cache.Entry, *progress.Summary, error) {
+	remotePath := filepath.Join(sshClient.GetRemoteDir(), archiveName)
+
+	if entry, ok := archiveCache.Lookup(archiveName); ok && checksum != "" {
+		if entry.Checksum == checksum && verifyCached(archiveCache, entry) == nil {
+			fmt.Fprintf(out, "Using cached %s\n", archiveName)
+			archiveCache.Touch(entry)
+			return entry, nil, nil
+		}
+		fmt.Fprintf(out, "Cached %s is outdated, downloading again\n", archiveName)
+	} else if ok {
+		size, err := sshClient.GetFileSize(remotePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to check remote archive: %w", err)
+		}
+		if size == entry.Size && verifyCached(archiveCache, entry) == nil {
+			fmt.Fprintf(out, "Using cached %s\n", archiveName)
+			archiveCache.Touch(entry)
+			return entry, nil, nil
+		}
+		fmt.Fprintf(out, "Cached %s is outdated, downloading again\n", archiveName)
+	}
+
+	// Download archive. An interrupted download of a previous run is
+	// resumed if the index lists the checksum to verify it against.
+	localPath, release, err := archiveCache.ClaimDownload(archiveName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	defer os.Remove(localPath)
+
+	fmt.Fprintf(out, "Downloading %s...\n", archiveName)
+	bar := board.Start(archiveName, 0)
+	err = sshClient.DownloadFile(remotePath, localPath, checksum, bar.Update)
+	summary := bar.Finish()
+	if err != nil {
+		if errors.Is(err, ssh.ErrChecksumMismatch) {
+			return nil, nil, fmt.Errorf("failed to download package: %w (run pm registry reindex if the archive was replaced)", err)
+		}
+		return nil, nil, fmt.Errorf("failed to download package: %w", err)
+	}
+
+	fmt.Fprintf(out, "Downloaded %s: %s\n", archiveName, summary)
+
+	entry, err := archiveCache.Add(name, version, localPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to cache package: %w", err)
+	}
+	return entry, &summary, nil
+}
+
+// verifyCached checks that a cached archive still matches its checksum
+func verifyCached(archiveCache *cache.Cache, entry *cache.Entry) error {
+	checksum, err := utils.FileChecksum(archiveCache.Path(entry))
+	if err != nil {
+		return err
+	}
+	if checksum != entry.Checksum {
+		return fmt.Errorf("cached archive %s is corrupted", entry.Archive)
+	}
+	return nil
+}
+
+// installFromCache installs a single package without network access, using
+// the version pinned in the lockfile or the best cached version. It reports
+// whether the package was installed.
+func (r *updateRun) installFromCache(pkg config.PackageRequest) (bool, error) {
+	entry, err := resolveOffline(r.cache, r.lockfile, pkg)
+	if err != nil {
+		return false, err
+	}
+	if r.isUpToDate(pkg, entry.Archive, entry.Checksum) {
+		return false, nil
+	}
+	if err := verifyCached(r.cache, entry); err != nil {
+		return false, err
+	}
+
+	fmt.Printf("Using cached %s\n", entry.Archive)
+	r.cache.Touch(entry)
+
+	if err := installArchive(r.db, r.tx, pkg.Name, entry.Version, pkg.Dest, r.cache.Path(entry), r.scriptsAllowed(pkg.Name)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// resolveOffline picks the cached archive to install for pkg. The lockfile
+// entry wins if it satisfies the constraint and is cached; otherwise the
+// highest cached version satisfying the constraint is used.
+func resolveOffline(archiveCache *cache.Cache, lockfile *config.Lockfile, pkg config.PackageRequest) (*cache.Entry, error) {
+	if locked, ok := lockfile.Find(pkg.Name); ok {
+		// A locked pre-release was selected through a tag, so it only has
+		// to satisfy an explicit constraint
+		version, err := parseVersion(locked.Version)
+		if err == nil && (pkg.Version == "" || version.satisfiesConstraint(pkg.Version)) {
+			if entry, ok := archiveCache.Lookup(locked.Archive); ok && entry.Checksum == locked.Checksum {
+				return entry, nil
+			}
+		}
+	}
+
+	var best *cache.Entry
+	var bestVersion Version
+	for _, entry := range archiveCache.List() {
+		if entry.Name != pkg.Name {
+			continue
+		}
+		version, err := parseVersion(entry.Version)
+		if err != nil || !version.satisfiesConstraint(pkg.Version) {
+			continue
+		}
+		if _, ok := archiveCache.Lookup(entry.Archive); !ok {
+			continue
+		}
+		if best == nil || version.Compare(bestVersion) > 0 {
+			best, bestVersion = entry, version
+		}
+	}
+
+	if best == nil {
+		if pkg.Version != "" {
+			return nil, fmt.Errorf("no cached package found for %s matching constraint %s", pkg.Name, pkg.Version)
+		}
+		return nil, fmt.Errorf("no cached package found for %s", pkg.Name)
+	}
+	return best, nil
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/cache"
+)
+
+func newTestCache(t *testing.T, versions ...string) *cache.Cache {
+	t.Helper()
+
+	archiveCache, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("cache.Open() error = %v", err)
+	}
+
+	archives := t.TempDir()
+	for _, version := range versions {
+		archive := filepath.Join(archives, "foo-"+version+".tar.gz")
+		os.WriteFile(archive, []byte(version), 0644)
+		if _, err := archiveCache.Add("foo", version, archive); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	return archiveCache
+}
+
+func TestResolveOffline(t *testing.T) {
+	archiveCache := newTestCache(t, "1.0.0", "1.1.0", "2.0.0")
+	locked, _ := archiveCache.Lookup("foo-1.1.0.tar.gz")
+
+	lockfile := &config.Lockfile{
+		Packages: []config.LockedPackage{
+			{Name: "foo", Version: "1.1.0", Archive: locked.Archive, Checksum: locked.Checksum},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		lockfile    *config.Lockfile
+		constraint  string
+		expected    string
+		expectError bool
+	}{
+		{name: "lockfile wins", lockfile: lockfile, expected: "1.1.0"},
+		{name: "highest cached without lockfile", lockfile: &config.Lockfile{}, expected: "2.0.0"},
+		{name: "lockfile outside constraint", lockfile: lockfile, constraint: ">=2.0.0", expected: "2.0.0"},
+		{name: "constraint filters cache", lockfile: &config.Lockfile{}, constraint: "<1.1.0", expected: "1.0.0"},
+		{name: "nothing cached matches", lockfile: &config.Lockfile{}, constraint: ">=3.0.0", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := resolveOffline(archiveCache, tt.lockfile, config.PackageRequest{Name: "foo", Version: tt.constraint})
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("resolveOffline() expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("resolveOffline() unexpected error: %v", err)
+				return
+			}
+
+			if entry.Version != tt.expected {
+				t.Errorf("resolveOffline() = %s, want %s", entry.Version, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveOfflineLockedChecksumMismatch(t *testing.T) {
+	archiveCache := newTestCache(t, "1.0.0", "1.1.0")
+
+	lockfile := &config.Lockfile{
+		Packages: []config.LockedPackage{
+			{Name: "foo", Version: "1.0.0", Archive: "foo-1.0.0.tar.gz", Checksum: "different"},
+		},
+	}
+
+	entry, err := resolveOffline(archiveCache, lockfile, config.PackageRequest{Name: "foo"})
+	if err != nil {
+		t.Fatalf("resolveOffline() unexpected error: %v", err)
+	}
+	if entry.Version != "1.1.0" {
+		t.Errorf("resolveOffline() = %s, want fallback to 1.1.0", entry.Version)
+	}
+}
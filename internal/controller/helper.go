@@ -1,16 +1,15 @@
 package controller
 
 import (
+	"cmp"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
 	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/rasadov/package-manager/config"
 	"github.com/rasadov/package-manager/internal/ssh"
-	"github.com/rasadov/package-manager/internal/utils"
 )
 
 // PackageCandidate represents a package file with parsed version
@@ -24,12 +23,19 @@ type Version struct {
 	Major int
 	Minor int
 	Patch int
-	Raw   string
+	// Prerelease is the part after the first dash, as in "1.0.0-rc.1"
+	Prerelease string
+	Raw        string
 }
 
-// parseVersion parses a version string like "1.0.12" into a Version struct
+// parseVersion parses a version string like "1.0.12" or "1.1.0-rc.1" into a Version struct
 func parseVersion(versionStr string) (Version, error) {
-	parts := strings.Split(versionStr, ".")
+	core, prerelease, hasPrerelease := strings.Cut(versionStr, "-")
+	if hasPrerelease && !validPrerelease(prerelease) {
+		return Version{}, fmt.Errorf("invalid pre-release version: %s", prerelease)
+	}
+
+	parts := strings.Split(core, ".")
 	if len(parts) < 2 || len(parts) > 3 {
 		return Version{}, fmt.Errorf("invalid version format: %s", versionStr)
 	}
@@ -62,13 +68,67 @@ func parseVersion(versionStr string) (Version, error) {
 	}
 
 	return Version{
-		Major: major,
-		Minor: minor,
-		Patch: patch,
-		Raw:   versionStr,
+		Major:      major,
+		Minor:      minor,
+		Patch:      patch,
+		Prerelease: prerelease,
+		Raw:        versionStr,
 	}, nil
 }
 
+// validPrerelease reports whether s is a dot-separated list of non-empty
+// alphanumeric identifiers
+func validPrerelease(s string) bool {
+	for _, identifier := range strings.Split(s, ".") {
+		if identifier == "" {
+			return false
+		}
+		for _, r := range identifier {
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// comparePrerelease orders pre-release strings like semantic versioning:
+// a release is newer than its pre-releases, numeric identifiers compare
+// numerically and sort before alphanumeric ones
+func comparePrerelease(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return 1
+	}
+	if b == "" {
+		return -1
+	}
+
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.Atoi(aParts[i])
+		bNum, bErr := strconv.Atoi(bParts[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNum != bNum {
+				return cmp.Compare(aNum, bNum)
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if aParts[i] != bParts[i] {
+				return strings.Compare(aParts[i], bParts[i])
+			}
+		}
+	}
+	return cmp.Compare(len(aParts), len(bParts))
+}
+
 // Compare compares two versions. Returns:
 // -1 if v < other
 //
@@ -97,7 +157,7 @@ func (v Version) Compare(other Version) int {
 		return -1
 	}
 
-	return 0
+	return comparePrerelease(v.Prerelease, other.Prerelease)
 }
 
 // String returns the string representation of the version
@@ -108,7 +168,7 @@ func (v Version) String() string {
 // satisfiesConstraint checks if version satisfies the given constraint
 func (v Version) satisfiesConstraint(constraint string) bool {
 	if constraint == "" {
-		return true // No constraint means any version is acceptable
+		return v.Prerelease == "" // No constraint means any release is acceptable
 	}
 
 	// Parse constraint (e.g., ">=1.0.0", "<=2.0.0", "1.0.0")
@@ -143,6 +203,11 @@ func (v Version) satisfiesConstraint(constraint string) bool {
 		return false // Invalid constraint
 	}
 
+	// Pre-releases only match constraints that name a pre-release
+	if v.Prerelease != "" && targetVersion.Prerelease == "" {
+		return false
+	}
+
 	comparison := v.Compare(targetVersion)
 
 	switch operator {
@@ -175,12 +240,23 @@ func extractVersionFromFilename(filename, packageName string) (string, error) {
 	return versionStr, nil
 }
 
-// findBestPackageVersion finds the best matching package version on the server
-func findBestPackageVersion(sshClient *ssh.Client, pkg config.PackageRequest) (string, error) {
-	// List files in remote directory
-	files, err := sshClient.ListFiles(sshClient.GetRemoteDir())
-	if err != nil {
-		return "", fmt.Errorf("failed to list remote files: %w", err)
+// findBestPackageVersion finds the best matching package version on the server.
+// Candidates come from the package index, or from the remote directory if
+// index is nil. Yanked versions, as listed in meta, are skipped unless
+// locked names their archive. A package with a tag, or without one when channel is set,
+// resolves through that distribution tag; a missing channel tag falls back
+// to the constraint. Progress is written to out.
+func findBestPackageVersion(sshClient *ssh.Client, index *RegistryIndex, meta *PackageMeta, pkg config.PackageRequest, channel, locked string, out io.Writer) (string, error) {
+	var files []string
+	if index != nil {
+		files = index.archives(pkg.Name)
+	} else {
+		// List files in remote directory
+		var err error
+		files, err = sshClient.ListFiles(sshClient.GetRemoteDir())
+		if err != nil {
+			return "", fmt.Errorf("failed to list remote files: %w", err)
+		}
 	}
 
 	// Find matching packages and parse their versions
@@ -192,13 +268,13 @@ func findBestPackageVersion(sshClient *ssh.Client, pkg config.PackageRequest) (s
 		if strings.HasPrefix(file, prefix) && strings.HasSuffix(file, suffix) {
 			versionStr, err := extractVersionFromFilename(file, pkg.Name)
 			if err != nil {
-				fmt.Printf("Warning: Could not parse version from %s: %v\n", file, err)
+				fmt.Fprintf(out, "Warning: Could not parse version from %s: %v\n", file, err)
 				continue
 			}
 
 			version, err := parseVersion(versionStr)
 			if err != nil {
-				fmt.Printf("Warning: Invalid version format in %s: %v\n", file, err)
+				fmt.Fprintf(out, "Warning: Invalid version format in %s: %v\n", file, err)
 				continue
 			}
 
@@ -213,9 +289,30 @@ func findBestPackageVersion(sshClient *ssh.Client, pkg config.PackageRequest) (s
 		return "", fmt.Errorf("no packages found for %s", pkg.Name)
 	}
 
-	fmt.Printf("Found %d candidate(s) for %s:\n", len(candidates), pkg.Name)
+	candidates = skipYanked(candidates, meta, locked, out)
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("all versions of %s are yanked", pkg.Name)
+	}
+
+	tag := pkg.Tag
+	if tag == "" {
+		tag = channel
+	}
+	if tag != "" {
+		if _, ok := meta.Tags[tag]; ok || pkg.Tag != "" {
+			selected, err := resolveTag(candidates, meta, pkg, tag)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(out, "Selected: %s (version %s) from tag %s\n", selected.Filename, selected.Version, tag)
+			return selected.Filename, nil
+		}
+		fmt.Fprintf(out, "%s has no %s tag, resolving by version\n", pkg.Name, tag)
+	}
+
+	fmt.Fprintf(out, "Found %d candidate(s) for %s:\n", len(candidates), pkg.Name)
 	for _, candidate := range candidates {
-		fmt.Printf("  - %s (version %s)\n", candidate.Filename, candidate.Version)
+		fmt.Fprintf(out, "  - %s (version %s)\n", candidate.Filename, candidate.Version)
 	}
 
 	// Filter candidates that satisfy version constraint
@@ -236,47 +333,98 @@ func findBestPackageVersion(sshClient *ssh.Client, pkg config.PackageRequest) (s
 	})
 
 	selected := validCandidates[0]
-	fmt.Printf("Selected: %s (version %s) from %d valid candidates\n",
+	fmt.Fprintf(out, "Selected: %s (version %s) from %d valid candidates\n",
 		selected.Filename, selected.Version, len(validCandidates))
 
 	return selected.Filename, nil
 }
 
-// downloadAndInstallPackage downloads and extracts a single package
-func downloadAndInstallPackage(sshClient *ssh.Client, pkg config.PackageRequest) error {
-	// Find the best matching package version on server
-	archiveName, err := findBestPackageVersion(sshClient, pkg)
-	if err != nil {
-		return fmt.Errorf("failed to find package version: %w", err)
+// skipYanked drops the yanked candidates, except the one whose archive is locked
+func skipYanked(candidates []PackageCandidate, meta *PackageMeta, locked string, out io.Writer) []PackageCandidate {
+	var selectable []PackageCandidate
+	for _, candidate := range candidates {
+		if meta.IsYanked(candidate.Version.Raw) && candidate.Filename != locked {
+			fmt.Fprintf(out, "Skipping %s, version %s was yanked\n", candidate.Filename, candidate.Version)
+			continue
+		}
+		selectable = append(selectable, candidate)
 	}
+	return selectable
+}
 
-	// Create temporary directory for download
-	tempDir, err := os.MkdirTemp("", "pm-download-*")
-	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %w", err)
+// resolveTag returns the candidate a distribution tag points at, which must
+// satisfy the version constraint of the request if it has one
+func resolveTag(candidates []PackageCandidate, meta *PackageMeta, pkg config.PackageRequest, tag string) (PackageCandidate, error) {
+	version, ok := meta.Tags[tag]
+	if !ok {
+		return PackageCandidate{}, fmt.Errorf("%s has no tag %s", pkg.Name, tag)
 	}
-	defer os.RemoveAll(tempDir)
 
-	// Download archive
-	remotePath := filepath.Join(sshClient.GetRemoteDir(), archiveName)
-	localPath := filepath.Join(tempDir, archiveName)
+	for _, candidate := range candidates {
+		if candidate.Version.Raw != version {
+			continue
+		}
+		if pkg.Version != "" && !candidate.Version.satisfiesConstraint(pkg.Version) {
+			return PackageCandidate{}, fmt.Errorf("tag %s of %s points to %s, which does not match constraint %s", tag, pkg.Name, version, pkg.Version)
+		}
+		return candidate, nil
+	}
+	return PackageCandidate{}, fmt.Errorf("tag %s of %s points to %s, which is not available", tag, pkg.Name, version)
+}
 
-	fmt.Printf("Downloading %s...\n", archiveName)
-	if err := sshClient.DownloadFile(remotePath, localPath); err != nil {
-		return fmt.Errorf("failed to download package: %w", err)
+// fetchPackage resolves a single package over sshClient, downloads its
+// archive into the cache unless the selected version is already installed
+// and extracts it into a staging directory. Progress is written to out.
+// The staged install is nil when the package is up to date.
+func (r *updateRun) fetchPackage(sshClient *ssh.Client, pkg config.PackageRequest, out io.Writer) (*fetchedPackage, error) {
+	// Find the best matching package version on server. A yanked version
+	// stays installable while the lockfile pins it.
+	locked, _ := r.lockfile.Find(pkg.Name)
+	archiveName, err := findBestPackageVersion(sshClient, r.index, r.meta[pkg.Name], pkg, r.opts.Channel, locked.Archive, out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find package version: %w", err)
+	}
+
+	version, err := extractVersionFromFilename(archiveName, pkg.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine package version: %w", err)
+	}
+	fetched := &fetchedPackage{archive: archiveName}
+
+	// The lockfile knows the checksum of the archive it pinned, which
+	// avoids fetching an archive that is already installed
+	if locked.Archive == archiveName {
+		fetched.checksum = locked.Checksum
+		if r.isUpToDate(pkg, archiveName, locked.Checksum) {
+			return fetched, nil
+		}
 	}
 
-	// Create installation directory
-	installDir := filepath.Join("packages", pkg.Name)
-	if err := os.MkdirAll(installDir, 0755); err != nil {
-		return fmt.Errorf("failed to create install directory: %w", err)
+	// The index knows the checksum of every archive as well
+	checksum := ""
+	if r.index != nil {
+		if indexed, ok := r.index.find(pkg.Name, archiveName); ok {
+			checksum = indexed.Checksum
+			fetched.checksum = checksum
+			if r.isUpToDate(pkg, archiveName, checksum) {
+				return fetched, nil
+			}
+		}
 	}
 
-	// Extract archive
-	fmt.Printf("Extracting %s to %s...\n", archiveName, installDir)
-	if err := utils.ExtractTarGz(localPath, installDir); err != nil {
-		return fmt.Errorf("failed to extract package: %w", err)
+	entry, transfer, err := fetchArchive(sshClient, r.cache, r.board, pkg.Name, version, archiveName, checksum, out)
+	if err != nil {
+		return nil, err
+	}
+	fetched.transfer = transfer
+	fetched.checksum = entry.Checksum
+	if r.isUpToDate(pkg, entry.Archive, entry.Checksum) {
+		return fetched, nil
 	}
 
-	return nil
+	fetched.staged, err = stageArchive(r.db.Root(), pkg.Name, version, pkg.Dest, r.cache.Path(entry), out)
+	if err != nil {
+		return nil, err
+	}
+	return fetched, nil
 }
@@ -32,6 +32,21 @@ func TestParseVersion(t *testing.T) {
 			versionStr: "10.20.30",
 			expected:   Version{Major: 10, Minor: 20, Patch: 30, Raw: "10.20.30"},
 		},
+		{
+			name:       "pre-release version",
+			versionStr: "1.2.0-rc.1",
+			expected:   Version{Major: 1, Minor: 2, Patch: 0, Prerelease: "rc.1", Raw: "1.2.0-rc.1"},
+		},
+		{
+			name:        "empty pre-release",
+			versionStr:  "1.2.0-",
+			expectError: true,
+		},
+		{
+			name:        "invalid pre-release",
+			versionStr:  "1.2.0-rc..1",
+			expectError: true,
+		},
 		{
 			name:        "single part version",
 			versionStr:  "1",
@@ -159,6 +174,56 @@ func TestVersionCompare(t *testing.T) {
 	}
 }
 
+func TestVersionComparePrerelease(t *testing.T) {
+	// Ascending order as defined by semantic versioning
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1-rc.1",
+	}
+
+	for i := 0; i < len(ordered)-1; i++ {
+		lower, err := parseVersion(ordered[i])
+		if err != nil {
+			t.Fatalf("parseVersion(%s) error = %v", ordered[i], err)
+		}
+		higher, err := parseVersion(ordered[i+1])
+		if err != nil {
+			t.Fatalf("parseVersion(%s) error = %v", ordered[i+1], err)
+		}
+		if lower.Compare(higher) != -1 || higher.Compare(lower) != 1 {
+			t.Errorf("expected %s < %s", ordered[i], ordered[i+1])
+		}
+	}
+}
+
+func TestPrereleaseSatisfiesConstraint(t *testing.T) {
+	prerelease, _ := parseVersion("2.0.0-rc.1")
+
+	tests := []struct {
+		constraint string
+		expected   bool
+	}{
+		{"", false},
+		{">=1.0.0", false},
+		{">=2.0.0-rc.0", true},
+		{"2.0.0-rc.1", true},
+		{"<2.0.0-rc.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := prerelease.satisfiesConstraint(tt.constraint); got != tt.expected {
+			t.Errorf("2.0.0-rc.1 satisfiesConstraint(%q) = %v, want %v", tt.constraint, got, tt.expected)
+		}
+	}
+}
+
 func TestVersionString(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,344 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/ssh"
+	"github.com/rasadov/package-manager/internal/utils"
+)
+
+const (
+	// indexFileName is the package index next to the published archives
+	indexFileName = "index.json"
+	// indexFormat is the index format written by this version of pm
+	indexFormat = 1
+)
+
+// IndexEntry describes a published archive in the package index
+type IndexEntry struct {
+	Version      string              `json:"ver"`
+	Archive      string              `json:"archive"`
+	Size         int64               `json:"size"`
+	Checksum     string              `json:"sha256"`
+	Dependencies []config.Dependency `json:"packets,omitempty"`
+	Published    time.Time           `json:"published"`
+}
+
+// RegistryIndex lists every published archive so clients can resolve
+// versions without listing the registry directory. Serial increases with
+// every change.
+type RegistryIndex struct {
+	Format   int                     `json:"format"`
+	Serial   int64                   `json:"serial"`
+	Updated  time.Time               `json:"updated"`
+	Packages map[string][]IndexEntry `json:"packages"`
+}
+
+// newRegistryIndex returns an empty index
+func newRegistryIndex() *RegistryIndex {
+	return &RegistryIndex{
+		Format:   indexFormat,
+		Packages: make(map[string][]IndexEntry),
+	}
+}
+
+// put adds an entry to the index, replacing the entry of the same version
+func (idx *RegistryIndex) put(name string, entry IndexEntry) {
+	idx.remove(name, entry.Version)
+	idx.Packages[name] = append(idx.Packages[name], entry)
+	sort.Slice(idx.Packages[name], func(i, j int) bool {
+		return idx.Packages[name][i].Archive < idx.Packages[name][j].Archive
+	})
+}
+
+// remove drops the entry of a version from the index
+func (idx *RegistryIndex) remove(name, version string) {
+	var kept []IndexEntry
+	for _, entry := range idx.Packages[name] {
+		if entry.Version != version {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == 0 {
+		delete(idx.Packages, name)
+		return
+	}
+	idx.Packages[name] = kept
+}
+
+// find returns the entry of an archive of the named package
+func (idx *RegistryIndex) find(name, archive string) (IndexEntry, bool) {
+	for _, entry := range idx.Packages[name] {
+		if entry.Archive == archive {
+			return entry, true
+		}
+	}
+	return IndexEntry{}, false
+}
+
+// archives returns the archive names of the named package
+func (idx *RegistryIndex) archives(name string) []string {
+	var archives []string
+	for _, entry := range idx.Packages[name] {
+		archives = append(archives, entry.Archive)
+	}
+	return archives
+}
+
+// remotePackages converts the index to published packages, sorted by name
+// with their versions newest first
+func (idx *RegistryIndex) remotePackages() []*RemotePackage {
+	var packages []*RemotePackage
+	for name, entries := range idx.Packages {
+		pkg := &RemotePackage{Name: name}
+		for _, entry := range entries {
+			version, err := parseVersion(entry.Version)
+			if err != nil {
+				continue
+			}
+			pkg.Versions = append(pkg.Versions, RemoteVersion{
+				Version:   entry.Version,
+				Archive:   entry.Archive,
+				Size:      entry.Size,
+				Published: entry.Published,
+				parsed:    version,
+			})
+		}
+		if len(pkg.Versions) == 0 {
+			continue
+		}
+		sort.Slice(pkg.Versions, func(i, j int) bool {
+			return pkg.Versions[i].parsed.Compare(pkg.Versions[j].parsed) > 0
+		})
+		packages = append(packages, pkg)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
+	return packages
+}
+
+// unindexed returns the archives among files that the index does not list
+func (idx *RegistryIndex) unindexed(files []string) []string {
+	indexed := make(map[string]bool)
+	for _, entries := range idx.Packages {
+		for _, entry := range entries {
+			indexed[entry.Archive] = true
+		}
+	}
+
+	var missing []string
+	for _, file := range files {
+		if strings.HasSuffix(file, ".tar.gz") && !indexed[file] {
+			missing = append(missing, file)
+		}
+	}
+	return missing
+}
+
+// warnUnindexed warns on out about published archives missing from the
+// package index, e.g. uploaded by older versions of pm, which update does
+// not see. Registries without an index have nothing to warn about.
+func warnUnindexed(sshClient *ssh.Client, out io.Writer) error {
+	idx, err := loadIndex(sshClient)
+	if err != nil || idx == nil {
+		return err
+	}
+	files, err := sshClient.ListFiles(sshClient.GetRemoteDir())
+	if err != nil {
+		return fmt.Errorf("failed to list remote files: %w", err)
+	}
+
+	missing := idx.unindexed(files)
+	if len(missing) > 0 {
+		fmt.Fprintf(out, "Warning: %d archive(s) missing from the package index are ignored by pm update: %s (run pm registry reindex)\n",
+			len(missing), strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// loadIndex fetches the package index, returning nil for registries
+// without one
+func loadIndex(sshClient *ssh.Client) (*RegistryIndex, error) {
+	data, err := sshClient.ReadFile(filepath.Join(sshClient.GetRemoteDir(), indexFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read package index: %w", err)
+	}
+
+	var idx RegistryIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("failed to parse package index: %w", err)
+	}
+	if idx.Format > indexFormat {
+		return nil, fmt.Errorf("package index format %d is not supported by this version of pm", idx.Format)
+	}
+	if idx.Packages == nil {
+		idx.Packages = make(map[string][]IndexEntry)
+	}
+	return &idx, nil
+}
+
+// saveIndex writes the package index, replacing the previous one atomically.
+// The registry lock must be held.
+func saveIndex(sshClient *ssh.Client, idx *RegistryIndex) error {
+	idx.Format = indexFormat
+	idx.Serial++
+	idx.Updated = time.Now().UTC()
+
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode package index: %w", err)
+	}
+
+	if err := sshClient.WriteFile(filepath.Join(sshClient.GetRemoteDir(), indexFileName), data); err != nil {
+		return fmt.Errorf("failed to write package index: %w", err)
+	}
+	return nil
+}
+
+// indexArchive reads a published archive once to checksum it and extract
+// the dependencies from its manifest
+func indexArchive(sshClient *ssh.Client, info os.FileInfo) (IndexEntry, error) {
+	remoteFile, err := sshClient.OpenFile(filepath.Join(sshClient.GetRemoteDir(), info.Name()))
+	if err != nil {
+		return IndexEntry{}, err
+	}
+	defer remoteFile.Close()
+
+	hash := sha256.New()
+	reader := io.TeeReader(remoteFile, hash)
+
+	var dependencies []config.Dependency
+	data, err := utils.ReadTarGzEntry(reader, manifestEntry)
+	if err == nil {
+		var manifest config.PacketConfig
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return IndexEntry{}, fmt.Errorf("failed to parse manifest of %s: %w", info.Name(), err)
+		}
+		dependencies = manifest.Dependencies
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return IndexEntry{}, fmt.Errorf("failed to read manifest of %s: %w", info.Name(), err)
+	}
+
+	// Hash the rest of the archive
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return IndexEntry{}, fmt.Errorf("failed to read %s: %w", info.Name(), err)
+	}
+
+	_, version, _ := splitArchiveName(info.Name())
+	return IndexEntry{
+		Version:      version.Raw,
+		Archive:      info.Name(),
+		Size:         info.Size(),
+		Checksum:     hex.EncodeToString(hash.Sum(nil)),
+		Dependencies: dependencies,
+		Published:    info.ModTime().UTC(),
+	}, nil
+}
+
+// buildIndex builds a package index from the published archives, refreshing
+// the held registry lock as it goes
+func buildIndex(sshClient *ssh.Client, lock *ssh.Lock) (*RegistryIndex, error) {
+	files, err := sshClient.ListFileInfos(sshClient.GetRemoteDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote files: %w", err)
+	}
+
+	idx := newRegistryIndex()
+	for _, file := range files {
+		name, _, ok := splitArchiveName(file.Name())
+		if !ok {
+			continue
+		}
+
+		fmt.Printf("Indexing %s...\n", file.Name())
+		entry, err := indexArchive(sshClient, file)
+		if err != nil {
+			return nil, err
+		}
+		idx.put(name, entry)
+
+		if err := lock.Refresh(0); err != nil {
+			return nil, fmt.Errorf("failed to refresh registry lock: %w", err)
+		}
+	}
+	return idx, nil
+}
+
+// modifyIndex applies change to the package index and saves it. A registry
+// without an index gets one built from its archives first. The registry
+// lock must be held.
+func modifyIndex(sshClient *ssh.Client, lock *ssh.Lock, change func(idx *RegistryIndex)) error {
+	idx, err := loadIndex(sshClient)
+	if err != nil {
+		return err
+	}
+	if idx == nil {
+		if idx, err = buildIndex(sshClient, lock); err != nil {
+			return err
+		}
+	}
+
+	change(idx)
+	return saveIndex(sshClient, idx)
+}
+
+// Reindex rebuilds the package index from the published archives
+func Reindex(sshConfig config.SSHConfig) error {
+	sshClient, err := connectRegistry(sshConfig)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	lock, err := lockRegistry(sshClient)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	idx, err := buildIndex(sshClient, lock)
+	if err != nil {
+		return err
+	}
+
+	// Keep the serial increasing across rebuilds
+	if previous, err := loadIndex(sshClient); err == nil && previous != nil {
+		idx.Serial = previous.Serial
+
+		var archives []string
+		for _, entries := range idx.Packages {
+			for _, entry := range entries {
+				archives = append(archives, entry.Archive)
+			}
+		}
+		if added := previous.unindexed(archives); len(added) > 0 {
+			sort.Strings(added)
+			fmt.Printf("Added %d archive(s) missing from the previous index: %s\n", len(added), strings.Join(added, ", "))
+		}
+	}
+	if err := saveIndex(sshClient, idx); err != nil {
+		return err
+	}
+
+	count := 0
+	for _, entries := range idx.Packages {
+		count += len(entries)
+	}
+	fmt.Printf("Indexed %d archive(s) of %d package(s)\n", count, len(idx.Packages))
+	return nil
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegistryIndexPutRemove(t *testing.T) {
+	idx := newRegistryIndex()
+	idx.put("foo", IndexEntry{Version: "1.1.0", Archive: "foo-1.1.0.tar.gz", Checksum: "a"})
+	idx.put("foo", IndexEntry{Version: "1.0.0", Archive: "foo-1.0.0.tar.gz", Checksum: "b"})
+	idx.put("bar", IndexEntry{Version: "2.0.0", Archive: "bar-2.0.0.tar.gz", Checksum: "c"})
+
+	// Republishing a version replaces its entry
+	idx.put("foo", IndexEntry{Version: "1.1.0", Archive: "foo-1.1.0.tar.gz", Checksum: "d"})
+
+	if got := idx.archives("foo"); !reflect.DeepEqual(got, []string{"foo-1.0.0.tar.gz", "foo-1.1.0.tar.gz"}) {
+		t.Errorf("archives(foo) = %v", got)
+	}
+	entry, ok := idx.find("foo", "foo-1.1.0.tar.gz")
+	if !ok || entry.Checksum != "d" {
+		t.Errorf("find(foo-1.1.0) = %+v, %v, want checksum d", entry, ok)
+	}
+
+	idx.remove("bar", "2.0.0")
+	if _, ok := idx.Packages["bar"]; ok {
+		t.Errorf("package without versions kept in the index")
+	}
+	if _, ok := idx.find("bar", "bar-2.0.0.tar.gz"); ok {
+		t.Errorf("find() returned a removed entry")
+	}
+}
+
+func TestRegistryIndexRemotePackages(t *testing.T) {
+	published := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	idx := newRegistryIndex()
+	idx.put("foo", IndexEntry{Version: "1.2.0", Archive: "foo-1.2.0.tar.gz", Size: 12, Published: published})
+	idx.put("foo", IndexEntry{Version: "1.10.0", Archive: "foo-1.10.0.tar.gz", Size: 110, Published: published})
+	idx.put("bar", IndexEntry{Version: "0.1.0", Archive: "bar-0.1.0.tar.gz", Size: 1, Published: published})
+
+	packages := idx.remotePackages()
+	if len(packages) != 2 || packages[0].Name != "bar" || packages[1].Name != "foo" {
+		t.Fatalf("remotePackages() = %v, want bar and foo", packages)
+	}
+
+	foo := packages[1]
+	if foo.Versions[0].Version != "1.10.0" || foo.Versions[1].Version != "1.2.0" {
+		t.Errorf("foo versions = %v, want newest first", foo.Versions)
+	}
+	if foo.Versions[0].Size != 110 || !foo.Versions[0].Published.Equal(published) {
+		t.Errorf("foo 1.10.0 = %+v", foo.Versions[0])
+	}
+}
+
+func TestRegistryIndexUnindexed(t *testing.T) {
+	idx := newRegistryIndex()
+	idx.put("foo", IndexEntry{Version: "1.0.0", Archive: "foo-1.0.0.tar.gz"})
+
+	files := []string{"foo-1.0.0.tar.gz", "foo-1.1.0.tar.gz", "index.json", ".lock", "bar-2.0.0.tar.gz.partial"}
+	if got := idx.unindexed(files); !reflect.DeepEqual(got, []string{"foo-1.1.0.tar.gz"}) {
+		t.Errorf("unindexed() = %v, want [foo-1.1.0.tar.gz]", got)
+	}
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/progress"
+)
+
+// PackageInfo is the metadata of a published package version
+type PackageInfo struct {
+	Name         string              `json:"name"`
+	Version      string              `json:"ver"`
+	Archive      string              `json:"archive"`
+	Size         int64               `json:"size"`
+	Published    time.Time           `json:"published"`
+	Description  string              `json:"description,omitempty"`
+	License      string              `json:"license,omitempty"`
+	Maintainers  []string            `json:"maintainers,omitempty"`
+	Dependencies []config.Dependency `json:"packets,omitempty"`
+	Versions     []string            `json:"versions"`
+	Yanked       []string            `json:"yanked,omitempty"`
+	Tags         map[string]string   `json:"tags,omitempty"`
+}
+
+// parsePackageSpec splits "name@constraint" into a package request
+func parsePackageSpec(spec string) (config.PackageRequest, error) {
+	name, constraint, _ := strings.Cut(spec, "@")
+	if name == "" {
+		return config.PackageRequest{}, fmt.Errorf("invalid package %q, expected name[@constraint]", spec)
+	}
+	return config.PackageRequest{Name: name, Version: constraint}, nil
+}
+
+// selectVersion returns the newest version of a published package that
+// satisfies constraint and was not yanked
+func selectVersion(pkg *RemotePackage, constraint string) (RemoteVersion, bool) {
+	for _, version := range pkg.Versions {
+		if !version.Yanked && version.parsed.satisfiesConstraint(constraint) {
+			return version, true
+		}
+	}
+	return RemoteVersion{}, false
+}
+
+// newPackageInfo combines a published version with the manifest embedded
+// in its archive, which may be nil
+func newPackageInfo(pkg *RemotePackage, version RemoteVersion, manifest *config.PacketConfig) *PackageInfo {
+	info := &PackageInfo{
+		Name:      pkg.Name,
+		Version:   version.Version,
+		Archive:   version.Archive,
+		Size:      version.Size,
+		Published: version.Published,
+		Tags:      pkg.Tags,
+	}
+	for _, v := range pkg.Versions {
+		info.Versions = append(info.Versions, v.Version)
+		if v.Yanked {
+			info.Yanked = append(info.Yanked, v.Version)
+		}
+	}
+	if manifest != nil {
+		info.Description = manifest.Description
+		info.License = manifest.License
+		info.Maintainers = manifest.Maintainers
+		info.Dependencies = manifest.Dependencies
+	}
+	return info
+}
+
+// Info prints the metadata of the newest published version of a package
+// matching spec, given as name[@constraint]
+func Info(sshConfig config.SSHConfig, spec string, jsonOutput bool) error {
+	request, err := parsePackageSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	sshClient, err := connectRegistry(sshConfig)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	packages, err := listRegistry(sshClient)
+	if err != nil {
+		return err
+	}
+
+	var pkg *RemotePackage
+	for _, candidate := range packages {
+		if candidate.Name == request.Name {
+			pkg = candidate
+			break
+		}
+	}
+	if pkg == nil {
+		return fmt.Errorf("no packages found for %s", request.Name)
+	}
+
+	version, ok := selectVersion(pkg, request.Version)
+	if !ok {
+		return fmt.Errorf("no packages found for %s matching constraint %s", request.Name, request.Version)
+	}
+
+	manifest, err := readRemoteManifest(sshClient, version.Archive)
+	if err != nil {
+		return err
+	}
+	info := newPackageInfo(pkg, version, manifest)
+
+	if jsonOutput {
+		return printJSON(info)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", info.Name)
+	fmt.Fprintf(w, "Version:\t%s\n", info.Version)
+	fmt.Fprintf(w, "Published:\t%s\n", info.Published.Local().Format("2006-01-02 15:04"))
+	fmt.Fprintf(w, "Size:\t%s\n", progress.FormatSize(info.Size))
+	if info.Description != "" {
+		fmt.Fprintf(w, "Description:\t%s\n", info.Description)
+	}
+	if info.License != "" {
+		fmt.Fprintf(w, "License:\t%s\n", info.License)
+	}
+	if len(info.Maintainers) > 0 {
+		fmt.Fprintf(w, "Maintainers:\t%s\n", strings.Join(info.Maintainers, ", "))
+	}
+	fmt.Fprintf(w, "Dependencies:\t%s\n", formatDependencies(info.Dependencies))
+	fmt.Fprintf(w, "Versions:\t%s\n", strings.Join(info.Versions, ", "))
+	if len(info.Yanked) > 0 {
+		fmt.Fprintf(w, "Yanked:\t%s\n", strings.Join(info.Yanked, ", "))
+	}
+	if len(info.Tags) > 0 {
+		tags := make([]string, 0, len(info.Tags))
+		for tag, tagged := range info.Tags {
+			tags = append(tags, tag+"="+tagged)
+		}
+		sort.Strings(tags)
+		fmt.Fprintf(w, "Tags:\t%s\n", strings.Join(tags, ", "))
+	}
+	return w.Flush()
+}
+
+// formatDependencies renders a dependency list like "bar >=1.0, baz"
+func formatDependencies(dependencies []config.Dependency) string {
+	if len(dependencies) == 0 {
+		return "none"
+	}
+
+	parts := make([]string, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		if dependency.Version == "" {
+			parts = append(parts, dependency.Name)
+		} else {
+			parts = append(parts, dependency.Name+" "+dependency.Version)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
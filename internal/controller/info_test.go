@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rasadov/package-manager/config"
+)
+
+func TestParsePackageSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    config.PackageRequest
+		wantErr bool
+	}{
+		{"foo", config.PackageRequest{Name: "foo"}, false},
+		{"foo@>=1.2", config.PackageRequest{Name: "foo", Version: ">=1.2"}, false},
+		{"@1.0.0", config.PackageRequest{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parsePackageSpec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePackageSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parsePackageSpec() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPackageInfo(t *testing.T) {
+	now := time.Now()
+	packages := groupArchives([]os.FileInfo{
+		fakeFileInfo{"foo-1.0.0.tar.gz", 10, now},
+		fakeFileInfo{"foo-2.0.0.tar.gz", 20, now},
+		fakeFileInfo{"foo-1.5.0.tar.gz", 15, now},
+	})
+	pkg := packages[0]
+
+	version, ok := selectVersion(pkg, "<2.0.0")
+	if !ok || version.Version != "1.5.0" {
+		t.Fatalf("selectVersion(<2.0.0) = %v, %v, want 1.5.0", version.Version, ok)
+	}
+	if _, ok := selectVersion(pkg, ">2.0.0"); ok {
+		t.Errorf("selectVersion(>2.0.0) found a version")
+	}
+
+	manifest := &config.PacketConfig{
+		Description:  "Foo",
+		License:      "MIT",
+		Maintainers:  []string{"dev@example.com"},
+		Dependencies: []config.Dependency{{Name: "bar", Version: ">=1.0"}, {Name: "baz"}},
+	}
+	info := newPackageInfo(pkg, version, manifest)
+
+	if info.Version != "1.5.0" || info.Size != 15 || info.License != "MIT" {
+		t.Errorf("newPackageInfo() = %+v", info)
+	}
+	if !reflect.DeepEqual(info.Versions, []string{"2.0.0", "1.5.0", "1.0.0"}) {
+		t.Errorf("Versions = %v, want newest first", info.Versions)
+	}
+	if got := formatDependencies(info.Dependencies); got != "bar >=1.0, baz" {
+		t.Errorf("formatDependencies() = %q", got)
+	}
+	if got := formatDependencies(nil); got != "none" {
+		t.Errorf("formatDependencies(nil) = %q, want none", got)
+	}
+}
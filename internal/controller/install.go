@@ -0,0 +1,525 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/state"
+	"github.com/rasadov/package-manager/internal/utils"
+)
+
+const (
+	// defaultInstallRoot is the directory packages are installed into
+	defaultInstallRoot = "packages"
+	// defaultKeepVersions is the number of installed versions kept in the local store
+	defaultKeepVersions = 3
+)
+
+// resolveInstallRoot returns the install root selected by the --prefix flag,
+// the install_dir of the packages file or the default, in that order
+func resolveInstallRoot(prefix string, packagesConfig *config.PackagesConfig) string {
+	if prefix != "" {
+		return prefix
+	}
+	if packagesConfig != nil && packagesConfig.InstallDir != "" {
+		return packagesConfig.InstallDir
+	}
+	return defaultInstallRoot
+}
+
+// loadInstallRoot returns the install root selected by prefix or by the
+// install_dir of the packages file at packagesPath, which may be empty
+func loadInstallRoot(prefix, packagesPath string) (string, error) {
+	packagesConfig, err := loadOptionalPackagesConfig(packagesPath)
+	if err != nil {
+		return "", err
+	}
+	return resolveInstallRoot(prefix, packagesConfig), nil
+}
+
+// loadOptionalPackagesConfig loads the packages file at packagesPath,
+// returning nil if packagesPath is empty
+func loadOptionalPackagesConfig(packagesPath string) (*config.PackagesConfig, error) {
+	if packagesPath == "" {
+		return nil, nil
+	}
+	packagesConfig, err := config.LoadPackagesConfig(packagesPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages config: %w", err)
+	}
+	return packagesConfig, nil
+}
+
+// stagedInstall is a package archive extracted into a staging directory
+// and ready to be moved into place
+type stagedInstall struct {
+	name        string
+	version     string
+	archivePath string
+	installDir  string
+	staging     string
+	// owned is set when installDir belongs to the package alone
+	owned       bool
+	replaces    []string
+	bin         map[string]string
+	configFiles []string
+	// paths are the root-relative paths of the files and commands installed
+	paths  []string
+	record *state.Package
+}
+
+// cleanup removes the staging directory
+func (s *stagedInstall) cleanup() {
+	os.RemoveAll(s.staging)
+}
+
+// installArchive extracts a package archive into a staging directory and
+// moves it into place as part of tx. dest is the install directory relative
+// to the install root and defaults to the package name. A directory named
+// after the package is owned by it and swapped in as a whole; any other
+// destination may be shared with other packages, so its files are swapped
+// in one by one instead. Lifecycle scripts embedded in the archive run
+// around the swap when allowScripts is set.
+func installArchive(db *state.DB, tx *transaction, name, version, dest, archivePath string, allowScripts bool) error {
+	staged, err := stageArchive(db.Root(), name, version, dest, archivePath, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer staged.cleanup()
+
+	return installStaged(db, tx, staged, allowScripts)
+}
+
+// stageArchive extracts a package archive into a staging directory below
+// root, writing progress to out. It does not touch the installed packages,
+// so several archives can be staged at once.
+func stageArchive(root, name, version, dest, archivePath string, out io.Writer) (*stagedInstall, error) {
+	if dest == "" {
+		dest = name
+	}
+	installDir, err := resolveInstallDir(root, dest)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create install root: %w", err)
+	}
+
+	staged := &stagedInstall{
+		name:        name,
+		version:     version,
+		archivePath: archivePath,
+		installDir:  installDir,
+		owned:       filepath.Clean(dest) == name,
+	}
+	manifest, err := readManifest(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	if manifest != nil {
+		staged.replaces = manifest.Replaces
+		staged.bin = manifest.Bin
+		staged.configFiles = manifest.ConfigFiles
+	}
+	paths, err := archivePaths(archivePath, root, installDir)
+	if err != nil {
+		return nil, err
+	}
+	bins, err := binPaths(staged.bin)
+	if err != nil {
+		return nil, err
+	}
+	staged.paths = append(paths, bins...)
+
+	// Extract into a staging directory on the same filesystem as the target
+	staged.staging, err = os.MkdirTemp(root, "."+name+".staging-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	if err := os.Chmod(staged.staging, 0755); err != nil {
+		staged.cleanup()
+		return nil, fmt.Errorf("failed to set staging directory permissions: %w", err)
+	}
+
+	fmt.Fprintf(out, "Extracting %s to %s...\n", filepath.Base(archivePath), installDir)
+	if err := utils.ExtractTarGz(archivePath, staged.staging); err != nil {
+		staged.cleanup()
+		return nil, fmt.Errorf("failed to extract package: %w", err)
+	}
+
+	staged.record, err = newInstallRecord(root, name, version, archivePath, staged.staging, installDir)
+	if err != nil {
+		staged.cleanup()
+		return nil, fmt.Errorf("failed to record installation: %w", err)
+	}
+	return staged, nil
+}
+
+// installStaged moves a staged package into place as part of tx
+func installStaged(db *state.DB, tx *transaction, staged *stagedInstall, allowScripts bool) error {
+	name, version := staged.name, staged.version
+	archivePath, installDir, staging := staged.archivePath, staged.installDir, staged.staging
+	owned, bin, record := staged.owned, staged.bin, staged.record
+
+	// Refuse to overwrite files owned by other packages unless the manifest
+	// declares that this package replaces them
+	takeover, err := checkConflicts(db, name, staged.paths, staged.replaces)
+	if err != nil {
+		return err
+	}
+
+	if err := runHook(archivePath, hookPreinstall, allowScripts, name, version, installDir); err != nil {
+		return err
+	}
+
+	// Keep the archive so this version can be restored without network access
+	if err := db.StoreArchive(name, archivePath); err != nil {
+		return fmt.Errorf("failed to store archive: %w", err)
+	}
+
+	// Drop the files that the previous version installed but this one no
+	// longer ships. Inside an owned directory this happens when user files
+	// are carried over to the staging directory.
+	previous, _ := db.Get(name)
+	if previous != nil {
+		stale := staleFiles(previous.Files, record.Files)
+		if len(stale) > 0 {
+			fmt.Printf("Removing %d file(s) dropped since version %s...\n", len(stale), previous.Version)
+		}
+
+		var outside []state.File
+		for _, file := range stale {
+			if !owned || !isWithin(filepath.Join(db.Root(), filepath.FromSlash(file.Path)), installDir) {
+				outside = append(outside, file)
+			}
+		}
+		modified, err := moveAsideFiles(db.Root(), tx, outside)
+		if err != nil {
+			return fmt.Errorf("failed to remove dropped files: %w", err)
+		}
+		warnModified(modified)
+	}
+
+	if owned {
+		// Keep user files from the current installation
+		modified, err := carryOverFiles(db.Root(), previous, record, installDir, staging)
+		if err != nil {
+			return fmt.Errorf("failed to preserve existing files: %w", err)
+		}
+		warnModified(modified)
+	}
+
+	// Keep locally edited config files, staging the new defaults next to them
+	pending, err := keepConfigFiles(db.Root(), staged.configFiles, previous, record, installDir, staging)
+	if err != nil {
+		return fmt.Errorf("failed to preserve config files: %w", err)
+	}
+
+	if owned {
+		if err := tx.swapIn(staging, installDir); err != nil {
+			return fmt.Errorf("failed to install package: %w", err)
+		}
+	} else {
+		targets := make([]string, 0, len(record.Files)+len(pending))
+		for _, file := range record.Files {
+			targets = append(targets, file.Path)
+		}
+		targets = append(targets, pending...)
+
+		for _, path := range targets {
+			target := filepath.Join(db.Root(), filepath.FromSlash(path))
+			relPath, err := filepath.Rel(installDir, target)
+			if err != nil {
+				return fmt.Errorf("failed to resolve %s: %w", path, err)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("failed to create directory for %s: %w", path, err)
+			}
+			if err := tx.swapIn(filepath.Join(staging, relPath), target); err != nil {
+				return fmt.Errorf("failed to install package: %w", err)
+			}
+		}
+	}
+
+	record.Pending = trackPending(db.Root(), previous, pending)
+
+	// Link the commands of the package into the shared bin directory
+	record.Bins, err = linkBins(db.Root(), tx, installDir, bin, previous)
+	if err != nil {
+		return fmt.Errorf("failed to link commands: %w", err)
+	}
+
+	if err := runHook(archivePath, hookPostinstall, allowScripts, name, version, installDir); err != nil {
+		return err
+	}
+
+	for owner, ownerPaths := range takeover {
+		fmt.Printf("Taking over %d file(s) from %s\n", len(ownerPaths), owner)
+		db.DisownFiles(owner, ownerPaths)
+	}
+	db.Put(record)
+	return nil
+}
+
+// resolveInstallDir joins dest to root, rejecting destinations outside of it
+func resolveInstallDir(root, dest string) (string, error) {
+	if filepath.IsAbs(dest) {
+		return "", fmt.Errorf("destination %s must be relative to the install root", dest)
+	}
+	installDir := filepath.Join(root, dest)
+	if !isWithin(installDir, root) {
+		return "", fmt.Errorf("destination %s is outside of the install root", dest)
+	}
+	if isWithin(installDir, filepath.Join(root, state.DirName)) {
+		return "", fmt.Errorf("destination %s is reserved for the state of pm", dest)
+	}
+	return installDir, nil
+}
+
+// isWithin reports whether path is dir or below it
+func isWithin(path, dir string) bool {
+	cleanPath := filepath.Clean(path)
+	cleanDir := filepath.Clean(dir)
+	return cleanPath == cleanDir || strings.HasPrefix(cleanPath, cleanDir+string(os.PathSeparator))
+}
+
+// moveAsideFiles removes installed files as part of tx. Files whose content
+// changed since install are kept and returned.
+func moveAsideFiles(root string, tx *transaction, files []state.File) ([]string, error) {
+	var modified []string
+	for _, file := range files {
+		filePath := filepath.Join(root, filepath.FromSlash(file.Path))
+
+		checksum, err := utils.FileChecksum(filePath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return modified, fmt.Errorf("failed to checksum %s: %w", file.Path, err)
+		}
+		if checksum != file.Checksum {
+			modified = append(modified, file.Path)
+			continue
+		}
+
+		if err := tx.moveAside(filePath); err != nil {
+			return modified, err
+		}
+	}
+	return modified, nil
+}
+
+// warnModified reports files kept because they were modified since install
+func warnModified(paths []string) {
+	for _, path := range paths {
+		fmt.Printf("Warning: kept %s, which was modified since install\n", path)
+	}
+}
+
+// recordHistory appends an entry for the current state of the named package
+func recordHistory(db *state.DB, name, action string) {
+	entry := state.HistoryEntry{
+		Action: action,
+		Time:   time.Now().UTC(),
+	}
+	if pkg, ok := db.Get(name); ok {
+		entry.Version = pkg.Version
+		entry.Archive = pkg.Archive
+		entry.Checksum = pkg.Checksum
+	}
+	db.AddHistory(name, entry)
+}
+
+// archivePaths returns the root-relative paths the files of an archive are
+// installed to when extracted into installDir
+func archivePaths(archivePath, root, installDir string) ([]string, error) {
+	entries, err := utils.ListTarGz(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive: %w", err)
+	}
+
+	relInstallDir, err := filepath.Rel(root, installDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve install directory: %w", err)
+	}
+
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		paths = append(paths, filepath.ToSlash(filepath.Join(relInstallDir, filepath.FromSlash(entry))))
+	}
+	return paths, nil
+}
+
+// newInstallRecord builds the state record of a package extracted from
+// archivePath into extractDir, which will be moved to installDir.
+// File paths are stored relative to root.
+func newInstallRecord(root, name, version, archivePath, extractDir, installDir string) (*state.Package, error) {
+	archiveChecksum, err := utils.FileChecksum(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum archive: %w", err)
+	}
+
+	entries, err := utils.ListTarGz(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive: %w", err)
+	}
+
+	relInstallDir, err := filepath.Rel(root, installDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve install directory: %w", err)
+	}
+
+	files := make([]state.File, 0, len(entries))
+	for _, entry := range entries {
+		checksum, err := utils.FileChecksum(filepath.Join(extractDir, filepath.FromSlash(entry)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to checksum extracted file %s: %w", entry, err)
+		}
+		files = append(files, state.File{
+			Path:     filepath.ToSlash(filepath.Join(relInstallDir, filepath.FromSlash(entry))),
+			Checksum: checksum,
+		})
+	}
+
+	return &state.Package{
+		Name:        name,
+		Version:     version,
+		Archive:     filepath.Base(archivePath),
+		Checksum:    archiveChecksum,
+		InstallDir:  filepath.ToSlash(relInstallDir),
+		InstalledAt: time.Now().UTC(),
+		Files:       files,
+	}, nil
+}
+
+// carryOverFiles copies the files of the current installation in installDir
+// that are not shipped by the new record into staging. Files the previous
+// version installed are dropped unless they were modified, in which case
+// they are kept and returned.
+func carryOverFiles(root string, previous, record *state.Package, installDir, staging string) ([]string, error) {
+	if _, err := os.Stat(installDir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	shipped := make(map[string]bool, len(record.Files))
+	for _, file := range record.Files {
+		shipped[file.Path] = true
+	}
+	installed := make(map[string]string)
+	if previous != nil {
+		for _, file := range previous.Files {
+			installed[file.Path] = file.Checksum
+		}
+	}
+
+	var modified []string
+	err := filepath.Walk(installDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if shipped[relPath] {
+			return nil
+		}
+
+		if checksum, ok := installed[relPath]; ok {
+			current, err := utils.FileChecksum(path)
+			if err != nil {
+				return err
+			}
+			if current == checksum {
+				return nil
+			}
+			modified = append(modified, relPath)
+		}
+
+		stagedPath, err := filepath.Rel(installDir, path)
+		if err != nil {
+			return err
+		}
+		return utils.CopyFile(path, filepath.Join(staging, stagedPath))
+	})
+
+	return modified, err
+}
+
+// staleFiles returns the files of a previous installation that are not
+// part of the new one
+func staleFiles(previous, current []state.File) []state.File {
+	kept := make(map[string]bool, len(current))
+	for _, file := range current {
+		kept[file.Path] = true
+	}
+
+	var stale []state.File
+	for _, file := range previous {
+		if !kept[file.Path] {
+			stale = append(stale, file)
+		}
+	}
+	return stale
+}
+
+// removeInstalledFiles deletes the given installed files below root and
+// prunes directories left empty. Files whose content changed since install
+// are kept and returned unless force is set.
+func removeInstalledFiles(root string, files []state.File, force bool) ([]string, error) {
+	var modified []string
+	dirs := make(map[string]bool)
+
+	for _, file := range files {
+		filePath := filepath.Join(root, filepath.FromSlash(file.Path))
+
+		checksum, err := utils.FileChecksum(filePath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return modified, fmt.Errorf("failed to checksum %s: %w", file.Path, err)
+		}
+
+		if checksum != file.Checksum {
+			modified = append(modified, file.Path)
+			if !force {
+				continue
+			}
+		}
+
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return modified, fmt.Errorf("failed to remove %s: %w", file.Path, err)
+		}
+		dirs[filepath.Dir(filePath)] = true
+	}
+
+	for dir := range dirs {
+		pruneEmptyDirs(root, dir)
+	}
+
+	return modified, nil
+}
+
+// pruneEmptyDirs removes dir and its parents while they are empty,
+// stopping at root
+func pruneEmptyDirs(root, dir string) {
+	cleanRoot := filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != cleanRoot && isWithin(dir, cleanRoot); dir = filepath.Dir(dir) {
+		// os.Remove fails on non-empty directories, which ends the walk
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
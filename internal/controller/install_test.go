@@ -0,0 +1,406 @@
+package controller
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/state"
+	"github.com/rasadov/package-manager/internal/utils"
+)
+
+// writeInstalledFiles creates files below root and returns their state records
+func writeInstalledFiles(t *testing.T, root string, files map[string]string) []state.File {
+	t.Helper()
+
+	var records []state.File
+	for path, content := range files {
+		fullPath := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		checksum, err := utils.FileChecksum(fullPath)
+		if err != nil {
+			t.Fatalf("Failed to checksum file: %v", err)
+		}
+		records = append(records, state.File{Path: path, Checksum: checksum})
+	}
+	return records
+}
+
+func TestStaleFiles(t *testing.T) {
+	previous := []state.File{
+		{Path: "foo/plugins/old.so", Checksum: "1"},
+		{Path: "foo/bin/tool", Checksum: "2"},
+		{Path: "foo/README", Checksum: "3"},
+	}
+	current := []state.File{
+		{Path: "foo/bin/tool", Checksum: "4"},
+		{Path: "foo/plugins/new.so", Checksum: "5"},
+		{Path: "foo/README", Checksum: "3"},
+	}
+
+	stale := staleFiles(previous, current)
+	expected := []state.File{{Path: "foo/plugins/old.so", Checksum: "1"}}
+	if !reflect.DeepEqual(stale, expected) {
+		t.Errorf("staleFiles() = %v, want %v", stale, expected)
+	}
+
+	if stale := staleFiles(nil, current); len(stale) != 0 {
+		t.Errorf("staleFiles() with no previous install = %v, want none", stale)
+	}
+}
+
+func TestRemoveInstalledFiles(t *testing.T) {
+	root := t.TempDir()
+
+	records := writeInstalledFiles(t, root, map[string]string{
+		"foo/bin/tool":      "tool",
+		"foo/lib/a.so":      "a",
+		"foo/etc/tool.conf": "default",
+	})
+
+	// User edits a file and creates one next to the package files
+	os.WriteFile(filepath.Join(root, "foo/etc/tool.conf"), []byte("edited"), 0644)
+	os.WriteFile(filepath.Join(root, "foo/notes.txt"), []byte("mine"), 0644)
+
+	modified, err := removeInstalledFiles(root, records, false)
+	if err != nil {
+		t.Fatalf("removeInstalledFiles() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(modified, []string{"foo/etc/tool.conf"}) {
+		t.Errorf("modified = %v, want [foo/etc/tool.conf]", modified)
+	}
+
+	for _, path := range []string{"foo/bin/tool", "foo/lib/a.so", "foo/bin", "foo/lib"} {
+		if _, err := os.Stat(filepath.Join(root, path)); !os.IsNotExist(err) {
+			t.Errorf("%s should have been removed", path)
+		}
+	}
+	for _, path := range []string{"foo/etc/tool.conf", "foo/notes.txt"} {
+		if _, err := os.Stat(filepath.Join(root, path)); err != nil {
+			t.Errorf("%s should have been kept: %v", path, err)
+		}
+	}
+}
+
+func TestRemoveInstalledFilesForce(t *testing.T) {
+	root := t.TempDir()
+
+	records := writeInstalledFiles(t, root, map[string]string{
+		"foo/etc/tool.conf": "default",
+	})
+	os.WriteFile(filepath.Join(root, "foo/etc/tool.conf"), []byte("edited"), 0644)
+
+	modified, err := removeInstalledFiles(root, records, true)
+	if err != nil {
+		t.Fatalf("removeInstalledFiles() error = %v", err)
+	}
+
+	if len(modified) != 1 {
+		t.Errorf("expected the modified file to be reported, got %v", modified)
+	}
+	if _, err := os.Stat(filepath.Join(root, "foo")); !os.IsNotExist(err) {
+		t.Errorf("empty package directory should have been pruned")
+	}
+	if _, err := os.Stat(root); err != nil {
+		t.Errorf("install root must never be pruned: %v", err)
+	}
+}
+
+func TestRemoveInstalledFilesMissing(t *testing.T) {
+	root := t.TempDir()
+
+	records := []state.File{{Path: "foo/gone.txt", Checksum: "abc"}}
+	modified, err := removeInstalledFiles(root, records, false)
+	if err != nil {
+		t.Fatalf("removeInstalledFiles() error = %v", err)
+	}
+	if len(modified) != 0 {
+		t.Errorf("missing files should not be reported as modified, got %v", modified)
+	}
+}
+
+// writeTestArchive creates a tar.gz package archive containing files
+func writeTestArchive(t *testing.T, archivePath string, files map[string]string) {
+	t.Helper()
+
+	outFile, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	defer outFile.Close()
+
+	gzWriter := gzip.NewWriter(outFile)
+	defer gzWriter.Close()
+
+	tarWriter := tar.NewWriter(gzWriter)
+	defer tarWriter.Close()
+
+	for name, content := range files {
+		header := &tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+		if _, err := tarWriter.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write content: %v", err)
+		}
+	}
+}
+
+func TestInstallArchiveUpgrade(t *testing.T) {
+	root := t.TempDir()
+	archives := t.TempDir()
+
+	db, err := state.Open(root)
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	v1 := filepath.Join(archives, "foo-1.0.0.tar.gz")
+	writeTestArchive(t, v1, map[string]string{
+		"bin/tool":       "tool v1",
+		"plugins/old.so": "old plugin",
+		"etc/tool.conf":  "default",
+	})
+
+	tx := &transaction{}
+	if err := installArchive(db, tx, "foo", "1.0.0", "", v1, false); err != nil {
+		t.Fatalf("installArchive(v1) error = %v", err)
+	}
+	tx.commit()
+
+	// The user adds a file and edits one the package no longer ships
+	os.WriteFile(filepath.Join(root, "foo/notes.txt"), []byte("mine"), 0644)
+	os.WriteFile(filepath.Join(root, "foo/etc/tool.conf"), []byte("edited"), 0644)
+
+	v2 := filepath.Join(archives, "foo-2.0.0.tar.gz")
+	writeTestArchive(t, v2, map[string]string{
+		"bin/tool":       "tool v2",
+		"plugins/new.so": "new plugin",
+	})
+
+	tx = &transaction{}
+	if err := installArchive(db, tx, "foo", "2.0.0", "", v2, false); err != nil {
+		t.Fatalf("installArchive(v2) error = %v", err)
+	}
+	tx.commit()
+
+	expected := map[string]string{
+		"foo/bin/tool":       "tool v2",
+		"foo/plugins/new.so": "new plugin",
+		"foo/notes.txt":      "mine",
+		"foo/etc/tool.conf":  "edited",
+	}
+	for path, content := range expected {
+		if got := readFileString(t, filepath.Join(root, path)); got != content {
+			t.Errorf("%s = %q, want %q", path, got, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "foo/plugins/old.so")); !os.IsNotExist(err) {
+		t.Errorf("file dropped upstream should have been removed")
+	}
+
+	record, _ := db.Get("foo")
+	if record.Version != "2.0.0" || len(record.Files) != 2 {
+		t.Errorf("unexpected record after upgrade: %+v", record)
+	}
+}
+
+func TestInstallArchiveFailureKeepsPreviousVersion(t *testing.T) {
+	root := t.TempDir()
+	archives := t.TempDir()
+
+	db, err := state.Open(root)
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	v1 := filepath.Join(archives, "foo-1.0.0.tar.gz")
+	writeTestArchive(t, v1, map[string]string{"bin/tool": "tool v1"})
+
+	tx := &transaction{}
+	if err := installArchive(db, tx, "foo", "1.0.0", "", v1, false); err != nil {
+		t.Fatalf("installArchive(v1) error = %v", err)
+	}
+	tx.commit()
+
+	broken := filepath.Join(archives, "foo-2.0.0.tar.gz")
+	os.WriteFile(broken, []byte("not a gzip archive"), 0644)
+
+	if err := installArchive(db, tx, "foo", "2.0.0", "", broken, false); err == nil {
+		t.Fatalf("installArchive() expected error for broken archive")
+	}
+
+	if got := readFileString(t, filepath.Join(root, "foo/bin/tool")); got != "tool v1" {
+		t.Errorf("previous version was modified by failed install: %q", got)
+	}
+	entries, _ := os.ReadDir(root)
+	for _, entry := range entries {
+		if entry.Name() != "foo" && entry.Name() != state.DirName {
+			t.Errorf("failed install left %s behind", entry.Name())
+		}
+	}
+}
+
+func TestInstallArchiveSharedDestination(t *testing.T) {
+	root := t.TempDir()
+	archives := t.TempDir()
+
+	db, err := state.Open(root)
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	// Another package and the user already have files in the shared tree
+	os.MkdirAll(filepath.Join(root, "bin"), 0755)
+	os.WriteFile(filepath.Join(root, "bin/other"), []byte("other tool"), 0755)
+
+	v1 := filepath.Join(archives, "foo-1.0.0.tar.gz")
+	writeTestArchive(t, v1, map[string]string{
+		"foo":        "foo v1",
+		"foo-helper": "helper",
+	})
+
+	tx := &transaction{}
+	if err := installArchive(db, tx, "foo", "1.0.0", "bin", v1, false); err != nil {
+		t.Fatalf("installArchive(v1) error = %v", err)
+	}
+	tx.commit()
+
+	v2 := filepath.Join(archives, "foo-2.0.0.tar.gz")
+	writeTestArchive(t, v2, map[string]string{"foo": "foo v2"})
+
+	tx = &transaction{}
+	if err := installArchive(db, tx, "foo", "2.0.0", "bin", v2, false); err != nil {
+		t.Fatalf("installArchive(v2) error = %v", err)
+	}
+
+	if got := readFileString(t, filepath.Join(root, "bin/foo")); got != "foo v2" {
+		t.Errorf("bin/foo = %q, want %q", got, "foo v2")
+	}
+	if _, err := os.Stat(filepath.Join(root, "bin/foo-helper")); !os.IsNotExist(err) {
+		t.Errorf("file dropped upstream should have been removed")
+	}
+
+	// Rolling back restores the previous version file by file
+	if err := tx.rollback(); err != nil {
+		t.Fatalf("rollback() error = %v", err)
+	}
+	if got := readFileString(t, filepath.Join(root, "bin/foo")); got != "foo v1" {
+		t.Errorf("bin/foo after rollback = %q, want %q", got, "foo v1")
+	}
+	if got := readFileString(t, filepath.Join(root, "bin/foo-helper")); got != "helper" {
+		t.Errorf("bin/foo-helper after rollback = %q, want %q", got, "helper")
+	}
+	if got := readFileString(t, filepath.Join(root, "bin/other")); got != "other tool" {
+		t.Errorf("unrelated file in shared tree changed: %q", got)
+	}
+
+	record, _ := db.Get("foo")
+	if record.InstallDir != "bin" {
+		t.Errorf("InstallDir = %q, want %q", record.InstallDir, "bin")
+	}
+}
+
+func TestResolveInstallDir(t *testing.T) {
+	tests := []struct {
+		name        string
+		dest        string
+		expected    string
+		expectError bool
+	}{
+		{name: "package directory", dest: "foo", expected: filepath.Join("root", "foo")},
+		{name: "shared tree", dest: "bin", expected: filepath.Join("root", "bin")},
+		{name: "install root", dest: ".", expected: "root"},
+		{name: "nested", dest: "share/foo", expected: filepath.Join("root", "share", "foo")},
+		{name: "escapes root", dest: "../etc", expectError: true},
+		{name: "absolute", dest: "/etc", expectError: true},
+		{name: "state directory", dest: ".pm", expectError: true},
+		{name: "command links", dest: ".pm/bin", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := resolveInstallDir("root", tt.dest)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("resolveInstallDir() expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("resolveInstallDir() unexpected error: %v", err)
+				return
+			}
+
+			if result != tt.expected {
+				t.Errorf("resolveInstallDir() = %s, want %s", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveInstallRoot(t *testing.T) {
+	withInstallDir := &config.PackagesConfig{InstallDir: "/opt/tools"}
+
+	if got := resolveInstallRoot("", nil); got != defaultInstallRoot {
+		t.Errorf("default install root = %s, want %s", got, defaultInstallRoot)
+	}
+	if got := resolveInstallRoot("", withInstallDir); got != "/opt/tools" {
+		t.Errorf("install_dir install root = %s, want /opt/tools", got)
+	}
+	if got := resolveInstallRoot("/srv", withInstallDir); got != "/srv" {
+		t.Errorf("prefix install root = %s, want /srv", got)
+	}
+}
+
+func TestLoadInstallRoot(t *testing.T) {
+	packagesPath := filepath.Join(t.TempDir(), "packages.json")
+	if err := os.WriteFile(packagesPath, []byte(`{"install_dir": "/opt/tools", "packages": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		prefix       string
+		packagesPath string
+		want         string
+		wantErr      bool
+	}{
+		{"no packages file", "", "", defaultInstallRoot, false},
+		{"install_dir", "", packagesPath, "/opt/tools", false},
+		{"prefix wins", "/srv", packagesPath, "/srv", false},
+		{"missing packages file", "", filepath.Join(t.TempDir(), "missing.json"), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadInstallRoot(tt.prefix, tt.packagesPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadInstallRoot() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("loadInstallRoot() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/progress"
+	"github.com/rasadov/package-manager/internal/ssh"
+)
+
+// DefaultJobs is the default number of packages downloaded in parallel
+const DefaultJobs = 4
+
+// fetchedPackage is the outcome of resolving and downloading a package
+type fetchedPackage struct {
+	archive  string
+	checksum string
+	// staged is nil when the package was already up to date
+	staged *stagedInstall
+	// transfer is nil when the archive was not downloaded
+	transfer *progress.Summary
+}
+
+// fetchJob is a package handled by a download worker. Its progress is
+// buffered and printed once the packages before it are done.
+type fetchJob struct {
+	pkg     config.PackageRequest
+	output  bytes.Buffer
+	fetched *fetchedPackage
+	err     error
+	done    chan struct{}
+}
+
+// fetchPackages resolves, downloads and stages packages in parallel, with
+// up to opts.Jobs workers that each use their own SFTP channel. The output
+// of every package is printed in the order of the packages file. On error
+// the remaining packages are not started and nothing stays staged.
+func (r *updateRun) fetchPackages(packages []config.PackageRequest) ([]*fetchedPackage, error) {
+	clients := r.openChannels(min(r.opts.Jobs, len(packages)))
+	defer func() {
+		for _, client := range clients[1:] {
+			client.Close()
+		}
+	}()
+
+	jobs := make([]*fetchJob, len(packages))
+	queue := make(chan *fetchJob, len(packages))
+	for i, pkg := range packages {
+		jobs[i] = &fetchJob{pkg: pkg, done: make(chan struct{})}
+		queue <- jobs[i]
+	}
+	close(queue)
+
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(1)
+		go func(client *ssh.Client) {
+			defer wg.Done()
+			for job := range queue {
+				if !failed.Load() {
+					fmt.Fprintf(&job.output, "Processing package: %s\n", job.pkg.Name)
+					job.fetched, job.err = r.fetchPackage(client, job.pkg, &job.output)
+					if job.err != nil {
+						failed.Store(true)
+					}
+				}
+				close(job.done)
+			}
+		}(client)
+	}
+
+	// Print the output of each package as soon as it and the ones before
+	// it are done. Jobs are started in order, so the first failure in
+	// order is always a job that ran.
+	var err error
+	for _, job := range jobs {
+		<-job.done
+		r.board.Write(job.output.Bytes())
+		if job.err != nil {
+			err = fmt.Errorf("failed to install package %s: %w", job.pkg.Name, job.err)
+			break
+		}
+	}
+	wg.Wait()
+
+	fetched := make([]*fetchedPackage, len(jobs))
+	for i, job := range jobs {
+		fetched[i] = job.fetched
+	}
+	if err != nil {
+		cleanupFetched(fetched)
+		return nil, err
+	}
+	return fetched, nil
+}
+
+// openChannels returns up to n clients for the download workers: the
+// connection of the run and extra SFTP channels on it. Servers limit the
+// sessions per connection, so fewer channels are used if opening fails.
+func (r *updateRun) openChannels(n int) []*ssh.Client {
+	clients := []*ssh.Client{r.sshClient}
+	for len(clients) < n {
+		client, err := r.sshClient.OpenChannel()
+		if err != nil {
+			fmt.Fprintf(r.board, "Warning: downloading with %d parallel job(s): %v\n", len(clients), err)
+			break
+		}
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// cleanupFetched removes the staging directories of fetched packages
+func cleanupFetched(fetched []*fetchedPackage) {
+	for _, f := range fetched {
+		if f != nil && f.staged != nil {
+			f.staged.cleanup()
+		}
+	}
+}
+
+// installFetched installs a fetched package as part of the run's
+// transaction and reports whether it was installed. Packages installed
+// earlier in the run can change whether it is up to date, so that is
+// checked again, and a package skipped while fetching is fetched now if
+// it turns out to be needed.
+func (r *updateRun) installFetched(pkg config.PackageRequest, fetched *fetchedPackage) (bool, error) {
+	if fetched.staged == nil {
+		if r.isUpToDate(pkg, fetched.archive, fetched.checksum) {
+			return false, nil
+		}
+		refetched, err := r.fetchPackage(r.sshClient, pkg, os.Stdout)
+		if err != nil {
+			return false, err
+		}
+		fetched.transfer = refetched.transfer
+		if refetched.staged == nil {
+			return false, nil
+		}
+		defer refetched.staged.cleanup()
+		fetched = refetched
+	} else if r.isUpToDate(pkg, fetched.staged.record.Archive, fetched.staged.record.Checksum) {
+		return false, nil
+	}
+
+	fmt.Printf("Installing %s (version %s)...\n", pkg.Name, fetched.staged.version)
+	if err := installStaged(r.db, r.tx, fetched.staged, r.scriptsAllowed(pkg.Name)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// printTransfers summarizes the downloads of a run per package. elapsed is
+// the time spent downloading, which overlaps between parallel downloads.
+func printTransfers(packages []config.PackageRequest, fetched []*fetchedPackage, elapsed time.Duration) {
+	total := progress.Summary{Duration: elapsed}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	downloads := 0
+	for i, f := range fetched {
+		if f == nil || f.transfer == nil {
+			continue
+		}
+		if downloads == 0 {
+			fmt.Fprintln(w, "Downloads:")
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", packages[i].Name, f.archive, f.transfer)
+		total.Bytes += f.transfer.Bytes
+		downloads++
+	}
+	if downloads > 0 {
+		fmt.Fprintf(w, "Downloaded %d archive(s): %s\n", downloads, total)
+	}
+	w.Flush()
+}
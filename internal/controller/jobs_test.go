@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/rasadov/package-manager/internal/state"
+)
+
+func TestStageArchivesInParallel(t *testing.T) {
+	root := t.TempDir()
+	archives := t.TempDir()
+
+	db, err := state.Open(root)
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	// bar claims a file of foo in the shared bin directory
+	foo := filepath.Join(archives, "foo-1.0.0.tar.gz")
+	writeTestArchive(t, foo, map[string]string{"tool": "foo tool"})
+	bar := filepath.Join(archives, "bar-1.0.0.tar.gz")
+	writeTestArchive(t, bar, map[string]string{"tool": "bar tool"})
+	baz := filepath.Join(archives, "baz-1.0.0.tar.gz")
+	writeTestArchive(t, baz, map[string]string{"README": "baz"})
+
+	requests := []struct {
+		name, dest, archive string
+	}{
+		{"foo", "bin", foo},
+		{"bar", "bin", bar},
+		{"baz", "", baz},
+	}
+
+	staged := make([]*stagedInstall, len(requests))
+	var wg sync.WaitGroup
+	for i, req := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := stageArchive(root, req.name, "1.0.0", req.dest, req.archive, io.Discard)
+			if err != nil {
+				t.Errorf("stageArchive(%s) error = %v", req.name, err)
+				return
+			}
+			staged[i] = s
+		}()
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+	for _, s := range staged {
+		defer s.cleanup()
+	}
+
+	// Installing in order finds the conflict as a serial update would
+	tx := &transaction{}
+	if err := installStaged(db, tx, staged[0], false); err != nil {
+		t.Fatalf("installStaged(foo) error = %v", err)
+	}
+	if err := installStaged(db, tx, staged[1], false); err == nil {
+		t.Fatalf("installStaged(bar) expected conflict error")
+	}
+	if err := installStaged(db, tx, staged[2], false); err != nil {
+		t.Fatalf("installStaged(baz) error = %v", err)
+	}
+	tx.commit()
+
+	if got := readFileString(t, filepath.Join(root, "bin/tool")); got != "foo tool" {
+		t.Errorf("bin/tool = %q, want %q", got, "foo tool")
+	}
+	if got := readFileString(t, filepath.Join(root, "baz/README")); got != "baz" {
+		t.Errorf("baz/README = %q, want %q", got, "baz")
+	}
+	if _, ok := db.Get("bar"); ok {
+		t.Errorf("conflicting package should not be recorded")
+	}
+}
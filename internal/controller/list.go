@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/rasadov/package-manager/internal/state"
+)
+
+// ListInstalled prints the packages recorded in the installed-package
+// database of the install root selected by prefix or the packages file at
+// packagesPath
+func ListInstalled(prefix, packagesPath string) error {
+	root, err := loadInstallRoot(prefix, packagesPath)
+	if err != nil {
+		return err
+	}
+	db, err := state.Load(root)
+	if err != nil {
+		return fmt.Errorf("failed to load state database: %w", err)
+	}
+
+	packages := db.List()
+	if len(packages) == 0 {
+		fmt.Println("No packages installed")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tINSTALLED\tFILES\tDIRECTORY")
+	for _, pkg := range packages {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+			pkg.Name, pkg.Version, pkg.InstalledAt.Local().Format("2006-01-02 15:04"), len(pkg.Files), pkg.InstallDir)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rasadov/package-manager/internal/ssh"
+)
+
+// metaDirName is the directory next to the published archives holding
+// registry metadata, one <name>.json file per package
+const metaDirName = ".meta"
+
+// Registry actions
+const (
+	actionYank   = "yank"
+	actionUnyank = "unyank"
+	actionDelete = "delete"
+	actionTag    = "tag"
+	actionUntag  = "untag"
+	// actionOverwrite records a version republished with --force
+	actionOverwrite = "overwrite"
+)
+
+// RegistryEvent records a change to the published versions of a package
+type RegistryEvent struct {
+	Action  string    `json:"action"`
+	Version string    `json:"ver"`
+	User    string    `json:"user,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// PackageMeta is the registry metadata of a published package
+type PackageMeta struct {
+	// Yanked lists versions that are not selected for new resolutions
+	Yanked []string `json:"yanked,omitempty"`
+	// Tags maps distribution tags such as "stable" to versions
+	Tags   map[string]string `json:"tags,omitempty"`
+	Events []RegistryEvent   `json:"events,omitempty"`
+}
+
+// IsYanked reports whether version was yanked
+func (m *PackageMeta) IsYanked(version string) bool {
+	for _, yanked := range m.Yanked {
+		if yanked == version {
+			return true
+		}
+	}
+	return false
+}
+
+// setYanked marks version as yanked or not and reports whether that
+// changed anything
+func (m *PackageMeta) setYanked(version string, yanked bool) bool {
+	if m.IsYanked(version) == yanked {
+		return false
+	}
+
+	if yanked {
+		m.Yanked = append(m.Yanked, version)
+		sort.Strings(m.Yanked)
+		return true
+	}
+
+	kept := m.Yanked[:0]
+	for _, v := range m.Yanked {
+		if v != version {
+			kept = append(kept, v)
+		}
+	}
+	m.Yanked = kept
+	return true
+}
+
+// setTag points tag at version and returns the version it pointed at before
+func (m *PackageMeta) setTag(tag, version string) string {
+	if m.Tags == nil {
+		m.Tags = make(map[string]string)
+	}
+	previous := m.Tags[tag]
+	m.Tags[tag] = version
+	return previous
+}
+
+// versionTags returns the sorted distribution tags pointing at version
+func versionTags(tags map[string]string, version string) []string {
+	var names []string
+	for tag, tagged := range tags {
+		if tagged == version {
+			names = append(names, tag)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addEvent appends an event to the metadata
+func (m *PackageMeta) addEvent(action, version, user string) {
+	m.Events = append(m.Events, RegistryEvent{
+		Action:  action,
+		Version: version,
+		User:    user,
+		Time:    time.Now().UTC(),
+	})
+}
+
+// metaPath returns the remote path of the metadata of the named package
+func metaPath(sshClient *ssh.Client, name string) string {
+	return filepath.Join(sshClient.GetRemoteDir(), metaDirName, name+".json")
+}
+
+// loadPackageMeta reads the registry metadata of the named package.
+// Packages without metadata get an empty one.
+func loadPackageMeta(sshClient *ssh.Client, name string) (*PackageMeta, error) {
+	data, err := sshClient.ReadFile(metaPath(sshClient, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &PackageMeta{}, nil
+		}
+		return nil, fmt.Errorf("failed to read registry metadata of %s: %w", name, err)
+	}
+
+	var meta PackageMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse registry metadata of %s: %w", name, err)
+	}
+	return &meta, nil
+}
+
+// loadRegistryMeta reads the registry metadata of the named packages,
+// listing the metadata directory once so packages without a metadata file
+// cost no reads. Those packages get an empty one.
+func loadRegistryMeta(sshClient *ssh.Client, names []string) (map[string]*PackageMeta, error) {
+	metaFiles, err := sshClient.ListFiles(filepath.Join(sshClient.GetRemoteDir(), metaDirName))
+	if err != nil {
+		// Registries without metadata have no metadata directory. Other
+		// errors would silently drop yanks and tags.
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to list registry metadata: %w", err)
+		}
+		metaFiles = nil
+	}
+	hasMeta := make(map[string]bool, len(metaFiles))
+	for _, file := range metaFiles {
+		hasMeta[strings.TrimSuffix(file, ".json")] = true
+	}
+
+	metas := make(map[string]*PackageMeta, len(names))
+	for _, name := range names {
+		if _, ok := metas[name]; ok {
+			continue
+		}
+		if !hasMeta[name] {
+			metas[name] = &PackageMeta{}
+			continue
+		}
+		meta, err := loadPackageMeta(sshClient, name)
+		if err != nil {
+			return nil, err
+		}
+		metas[name] = meta
+	}
+	return metas, nil
+}
+
+// savePackageMeta writes the registry metadata of the named package
+func savePackageMeta(sshClient *ssh.Client, name string, meta *PackageMeta) error {
+	if err := sshClient.EnsureRemoteDir(filepath.Join(sshClient.GetRemoteDir(), metaDirName)); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode registry metadata: %w", err)
+	}
+	if err := sshClient.WriteFile(metaPath(sshClient, name), data); err != nil {
+		return fmt.Errorf("failed to save registry metadata of %s: %w", name, err)
+	}
+	return nil
+}
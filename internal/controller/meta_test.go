@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPackageMetaSetYanked(t *testing.T) {
+	meta := &PackageMeta{}
+
+	if !meta.setYanked("1.1.0", true) || !meta.setYanked("1.0.0", true) {
+		t.Fatalf("setYanked() reported no change for new versions")
+	}
+	if meta.setYanked("1.0.0", true) {
+		t.Errorf("setYanked() reported a change for an already yanked version")
+	}
+	if !reflect.DeepEqual(meta.Yanked, []string{"1.0.0", "1.1.0"}) {
+		t.Errorf("Yanked = %v, want [1.0.0 1.1.0]", meta.Yanked)
+	}
+
+	if !meta.setYanked("1.0.0", false) {
+		t.Errorf("setYanked(false) reported no change for a yanked version")
+	}
+	if meta.IsYanked("1.0.0") || !meta.IsYanked("1.1.0") {
+		t.Errorf("IsYanked() after unyank = %v", meta.Yanked)
+	}
+	if meta.setYanked("2.0.0", false) {
+		t.Errorf("setYanked(false) reported a change for a version that is not yanked")
+	}
+}
+
+func TestSkipYanked(t *testing.T) {
+	var candidates []PackageCandidate
+	for _, raw := range []string{"1.0.0", "1.1.0", "1.2.0"} {
+		version, err := parseVersion(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		candidates = append(candidates, PackageCandidate{Filename: "foo-" + raw + ".tar.gz", Version: version})
+	}
+	meta := &PackageMeta{Yanked: []string{"1.1.0", "1.2.0"}}
+
+	tests := []struct {
+		name   string
+		locked string
+		want   []string
+	}{
+		{"not locked", "", []string{"1.0.0"}},
+		{"locked yanked version", "foo-1.2.0.tar.gz", []string{"1.0.0", "1.2.0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, candidate := range skipYanked(candidates, meta, tt.locked, io.Discard) {
+				got = append(got, candidate.Version.Raw)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("skipYanked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionTags(t *testing.T) {
+	tags := map[string]string{"stable": "1.0.0", "lts": "1.0.0", "beta": "1.1.0"}
+
+	if got := versionTags(tags, "1.0.0"); !reflect.DeepEqual(got, []string{"lts", "stable"}) {
+		t.Errorf("versionTags(1.0.0) = %v, want [lts stable]", got)
+	}
+	if got := versionTags(tags, "2.0.0"); len(got) != 0 {
+		t.Errorf("versionTags(2.0.0) = %v, want none", got)
+	}
+}
+
+func TestCheckUntagged(t *testing.T) {
+	meta := &PackageMeta{Tags: map[string]string{"stable": "1.0.0"}}
+
+	if err := checkUntagged(meta, "foo", "1.0.0"); err == nil || !strings.Contains(err.Error(), "tagged stable") {
+		t.Errorf("checkUntagged(1.0.0) error = %v, want tagged stable", err)
+	}
+	if err := checkUntagged(meta, "foo", "1.1.0"); err != nil {
+		t.Errorf("checkUntagged(1.1.0) error = %v", err)
+	}
+}
+
+func TestYankVersion(t *testing.T) {
+	meta := &PackageMeta{Tags: map[string]string{"stable": "1.0.0"}}
+
+	if _, err := yankVersion(meta, "foo", "1.0.0", false); err == nil || !strings.Contains(err.Error(), "tagged stable") {
+		t.Errorf("yankVersion(1.0.0) error = %v, want tagged stable", err)
+	}
+	if meta.IsYanked("1.0.0") {
+		t.Errorf("tagged version was yanked")
+	}
+
+	if changed, err := yankVersion(meta, "foo", "1.1.0", false); err != nil || !changed {
+		t.Errorf("yankVersion(1.1.0) = %v, %v, want changed", changed, err)
+	}
+	if changed, err := yankVersion(meta, "foo", "1.1.0", true); err != nil || !changed {
+		t.Errorf("yankVersion(1.1.0, undo) = %v, %v, want changed", changed, err)
+	}
+}
@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/progress"
+	"github.com/rasadov/package-manager/internal/ssh"
+)
+
+// PrunePolicy selects the published versions pm prune deletes
+type PrunePolicy struct {
+	// KeepPatches keeps the newest releases of every minor version, zero keeps all
+	KeepPatches int
+	// PrereleaseAge deletes pre-releases published longer ago, zero keeps all
+	PrereleaseAge time.Duration
+	// Protected holds archive names that are never deleted
+	Protected map[string]bool
+}
+
+// PruneOptions configures pm prune
+type PruneOptions struct {
+	Policy PrunePolicy
+	// Package restricts pruning to one package
+	Package string
+	// Lockfiles protect the archives they reference
+	Lockfiles []string
+	DryRun    bool
+}
+
+// pruneCandidate is a published version selected for deletion
+type pruneCandidate struct {
+	Package string
+	Version RemoteVersion
+	Reason  string
+}
+
+// planPrune returns the published versions the policy deletes. Versions
+// that a distribution tag points at are never deleted.
+func planPrune(packages []*RemotePackage, policy PrunePolicy, now time.Time) []pruneCandidate {
+	var candidates []pruneCandidate
+	for _, pkg := range packages {
+		// Versions are sorted newest first, so the releases seen first for
+		// a minor version are the ones kept
+		kept := make(map[[2]int]int)
+		for _, version := range pkg.Versions {
+			reason := ""
+			if version.parsed.Prerelease != "" {
+				if policy.PrereleaseAge > 0 && now.Sub(version.Published) > policy.PrereleaseAge {
+					reason = fmt.Sprintf("pre-release older than %s", policy.PrereleaseAge)
+				}
+			} else if policy.KeepPatches > 0 {
+				minor := [2]int{version.parsed.Major, version.parsed.Minor}
+				kept[minor]++
+				if kept[minor] > policy.KeepPatches {
+					reason = fmt.Sprintf("beyond the newest %d patch release(s) of %d.%d", policy.KeepPatches, minor[0], minor[1])
+				}
+			}
+
+			if reason == "" || policy.Protected[version.Archive] || len(versionTags(pkg.Tags, version.Version)) > 0 {
+				continue
+			}
+			candidates = append(candidates, pruneCandidate{Package: pkg.Name, Version: version, Reason: reason})
+		}
+	}
+	return candidates
+}
+
+// lockedArchives returns the archives referenced by the given lockfiles
+func lockedArchives(paths []string) (map[string]bool, error) {
+	archives := make(map[string]bool)
+	for _, path := range paths {
+		// A mistyped path must not silently drop the protection
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("failed to read lockfile: %w", err)
+		}
+		lockfile, err := config.LoadLockfile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range lockfile.Packages {
+			archives[pkg.Archive] = true
+		}
+	}
+	return archives, nil
+}
+
+// Prune deletes published versions according to the retention policy of
+// opts, or only reports them with DryRun
+func Prune(sshConfig config.SSHConfig, opts PruneOptions) error {
+	if opts.Policy.KeepPatches <= 0 && opts.Policy.PrereleaseAge <= 0 {
+		return fmt.Errorf("no retention policy given, use --keep-patches or --prerelease-age")
+	}
+
+	protected, err := lockedArchives(opts.Lockfiles)
+	if err != nil {
+		return err
+	}
+	opts.Policy.Protected = protected
+
+	sshClient, err := connectRegistry(sshConfig)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	var lock *ssh.Lock
+	if !opts.DryRun {
+		lock, err = lockRegistry(sshClient)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+	}
+
+	packages, err := listRegistry(sshClient)
+	if err != nil {
+		return err
+	}
+	if opts.Package != "" {
+		var selected []*RemotePackage
+		for _, pkg := range packages {
+			if pkg.Name == opts.Package {
+				selected = append(selected, pkg)
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("no packages found for %s", opts.Package)
+		}
+		packages = selected
+	}
+
+	candidates := planPrune(packages, opts.Policy, time.Now())
+	if len(candidates) == 0 {
+		fmt.Println("Nothing to prune")
+		return nil
+	}
+
+	if opts.DryRun {
+		var total int64
+		for _, candidate := range candidates {
+			fmt.Printf("Would delete %s (%s): %s\n", candidate.Version.Archive, progress.FormatSize(candidate.Version.Size), candidate.Reason)
+			total += candidate.Version.Size
+		}
+		fmt.Printf("%d archive(s) would be deleted, freeing %s\n", len(candidates), progress.FormatSize(total))
+		return nil
+	}
+
+	var total int64
+	var count int
+	var removeErr error
+	deleted := make(map[string][]string)
+	for _, candidate := range candidates {
+		// Deleting many archives over a slow link may outlast the lock
+		if err := lock.Refresh(0); err != nil {
+			if count > 0 {
+				fmt.Printf("Deleted %d archive(s) before losing the lock; run pm registry reindex\n", count)
+			}
+			return fmt.Errorf("failed to refresh registry lock: %w", err)
+		}
+
+		if err := sshClient.RemoveFile(filepath.Join(sshClient.GetRemoteDir(), candidate.Version.Archive)); err != nil {
+			removeErr = err
+			break
+		}
+		fmt.Printf("Deleted %s (%s): %s\n", candidate.Version.Archive, progress.FormatSize(candidate.Version.Size), candidate.Reason)
+		total += candidate.Version.Size
+		count++
+		deleted[candidate.Package] = append(deleted[candidate.Package], candidate.Version.Version)
+	}
+
+	// Record the deletions that succeeded even if one failed, so the index
+	// does not list archives that are gone
+	if err := recordDeletions(sshClient, lock, deleted, sshConfig.Username); err != nil {
+		return err
+	}
+	if removeErr != nil {
+		return fmt.Errorf("deleted %d of %d archive(s): %w", count, len(candidates), removeErr)
+	}
+
+	fmt.Printf("Deleted %d archive(s), freed %s\n", count, progress.FormatSize(total))
+	return nil
+}
+
+// recordDeletions removes deleted versions from the package index and
+// records them in the registry metadata. The registry lock must be held.
+func recordDeletions(sshClient *ssh.Client, lock *ssh.Lock, deleted map[string][]string, user string) error {
+	if len(deleted) == 0 {
+		return nil
+	}
+
+	if err := modifyIndex(sshClient, lock, func(idx *RegistryIndex) {
+		for name, versions := range deleted {
+			for _, version := range versions {
+				idx.remove(name, version)
+			}
+		}
+	}); err != nil {
+		return err
+	}
+	for name, versions := range deleted {
+		meta, err := loadPackageMeta(sshClient, name)
+		if err != nil {
+			return err
+		}
+		for _, version := range versions {
+			meta.setYanked(version, false)
+			meta.addEvent(actionDelete, version, user)
+		}
+		if err := savePackageMeta(sshClient, name, meta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rasadov/package-manager/config"
+)
+
+func TestPlanPrune(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := now.Add(-60 * 24 * time.Hour)
+	recent := now.Add(-24 * time.Hour)
+
+	packages := groupArchives([]os.FileInfo{
+		fakeFileInfo{"foo-1.0.0.tar.gz", 1, old},
+		fakeFileInfo{"foo-1.0.1.tar.gz", 1, old},
+		fakeFileInfo{"foo-1.0.2.tar.gz", 1, old},
+		fakeFileInfo{"foo-1.1.0.tar.gz", 1, old},
+		fakeFileInfo{"foo-1.2.0-ci.1.tar.gz", 1, old},
+		fakeFileInfo{"foo-1.2.0-ci.2.tar.gz", 1, recent},
+		fakeFileInfo{"bar-2.0.0.tar.gz", 1, old},
+		fakeFileInfo{"bar-2.0.1.tar.gz", 1, old},
+	})
+	tagged := groupArchives([]os.FileInfo{
+		fakeFileInfo{"foo-1.0.0.tar.gz", 1, old},
+		fakeFileInfo{"foo-1.0.2.tar.gz", 1, old},
+		fakeFileInfo{"foo-1.0.1.tar.gz", 1, old},
+		fakeFileInfo{"foo-1.1.0-rc.1.tar.gz", 1, old},
+	})
+	tagged[0].Tags = map[string]string{"lts": "1.0.0", "beta": "1.1.0-rc.1"}
+
+	tests := []struct {
+		name     string
+		packages []*RemotePackage
+		policy   PrunePolicy
+		expected []string
+	}{
+		{
+			name:     "keep patches",
+			policy:   PrunePolicy{KeepPatches: 1},
+			expected: []string{"bar-2.0.0.tar.gz", "foo-1.0.1.tar.gz", "foo-1.0.0.tar.gz"},
+		},
+		{
+			name:     "protected by tags",
+			packages: tagged,
+			policy:   PrunePolicy{KeepPatches: 1, PrereleaseAge: 30 * 24 * time.Hour},
+			expected: []string{"foo-1.0.1.tar.gz"},
+		},
+		{
+			name:     "pre-release age",
+			policy:   PrunePolicy{PrereleaseAge: 30 * 24 * time.Hour},
+			expected: []string{"foo-1.2.0-ci.1.tar.gz"},
+		},
+		{
+			name: "protected by lockfile",
+			policy: PrunePolicy{
+				KeepPatches:   2,
+				PrereleaseAge: 30 * 24 * time.Hour,
+				Protected:     map[string]bool{"foo-1.0.0.tar.gz": true},
+			},
+			expected: []string{"foo-1.2.0-ci.1.tar.gz"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.packages == nil {
+				tt.packages = packages
+			}
+			var archives []string
+			for _, candidate := range planPrune(tt.packages, tt.policy, now) {
+				archives = append(archives, candidate.Version.Archive)
+			}
+			if !reflect.DeepEqual(archives, tt.expected) {
+				t.Errorf("planPrune() = %v, want %v", archives, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLockedArchives(t *testing.T) {
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, "packages.lock.json")
+	lockfile := &config.Lockfile{Packages: []config.LockedPackage{{Name: "foo", Version: "1.0.0", Archive: "foo-1.0.0.tar.gz"}}}
+	if err := config.SaveLockfile(lockPath, lockfile); err != nil {
+		t.Fatalf("SaveLockfile() error = %v", err)
+	}
+
+	archives, err := lockedArchives([]string{lockPath})
+	if err != nil {
+		t.Fatalf("lockedArchives() error = %v", err)
+	}
+	if !archives["foo-1.0.0.tar.gz"] {
+		t.Errorf("lockedArchives() = %v, want foo-1.0.0.tar.gz", archives)
+	}
+
+	if _, err := lockedArchives([]string{filepath.Join(dir, "missing.lock.json")}); err == nil {
+		t.Errorf("lockedArchives() expected error for a missing lockfile")
+	}
+}
@@ -0,0 +1,298 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/progress"
+	"github.com/rasadov/package-manager/internal/ssh"
+	"github.com/rasadov/package-manager/internal/utils"
+)
+
+// RemoteVersion is a version of a package published on the server
+type RemoteVersion struct {
+	Version   string    `json:"ver"`
+	Archive   string    `json:"archive"`
+	Size      int64     `json:"size"`
+	Published time.Time `json:"published"`
+	Yanked    bool      `json:"yanked,omitempty"`
+
+	parsed Version
+}
+
+// RemotePackage is a package published on the server with its versions,
+// newest first
+type RemotePackage struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Keywords    []string          `json:"keywords,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Versions    []RemoteVersion   `json:"versions"`
+}
+
+// splitArchiveName splits an archive name like "my-package-1.0.12.tar.gz"
+// into the package name and version
+func splitArchiveName(filename string) (string, Version, bool) {
+	base, ok := strings.CutSuffix(filename, ".tar.gz")
+	if !ok {
+		return "", Version{}, false
+	}
+
+	// Package names may contain dashes, so take the first split whose
+	// remainder is a valid version
+	for i := 1; i < len(base); i++ {
+		if base[i] != '-' {
+			continue
+		}
+		if version, err := parseVersion(base[i+1:]); err == nil {
+			return base[:i], version, true
+		}
+	}
+	return "", Version{}, false
+}
+
+// groupArchives groups the package archives among files by package name.
+// Packages are sorted by name and their versions newest first.
+func groupArchives(files []os.FileInfo) []*RemotePackage {
+	byName := make(map[string]*RemotePackage)
+	for _, file := range files {
+		name, version, ok := splitArchiveName(file.Name())
+		if !ok {
+			continue
+		}
+
+		pkg, ok := byName[name]
+		if !ok {
+			pkg = &RemotePackage{Name: name}
+			byName[name] = pkg
+		}
+		pkg.Versions = append(pkg.Versions, RemoteVersion{
+			Version:   version.Raw,
+			Archive:   file.Name(),
+			Size:      file.Size(),
+			Published: file.ModTime().UTC(),
+			parsed:    version,
+		})
+	}
+
+	packages := make([]*RemotePackage, 0, len(byName))
+	for _, pkg := range byName {
+		sort.Slice(pkg.Versions, func(i, j int) bool {
+			return pkg.Versions[i].parsed.Compare(pkg.Versions[j].parsed) > 0
+		})
+		packages = append(packages, pkg)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
+	return packages
+}
+
+// connectRegistry connects to the package server
+func connectRegistry(sshConfig config.SSHConfig) (*ssh.Client, error) {
+	sshClient := ssh.NewClient(sshConfig)
+	if err := sshClient.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to SSH server: %w", err)
+	}
+	return sshClient, nil
+}
+
+// listRegistry returns the packages published on the server, with their
+// tags and yanked versions marked. The package index is used when the
+// registry has one.
+func listRegistry(sshClient *ssh.Client) ([]*RemotePackage, error) {
+	idx, err := loadIndex(sshClient)
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []*RemotePackage
+	if idx != nil {
+		packages = idx.remotePackages()
+	} else {
+		files, err := sshClient.ListFileInfos(sshClient.GetRemoteDir())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list remote files: %w", err)
+		}
+		packages = groupArchives(files)
+	}
+
+	names := make([]string, 0, len(packages))
+	for _, pkg := range packages {
+		names = append(names, pkg.Name)
+	}
+	metas, err := loadRegistryMeta(sshClient, names)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range packages {
+		meta := metas[pkg.Name]
+		for i := range pkg.Versions {
+			pkg.Versions[i].Yanked = meta.IsYanked(pkg.Versions[i].Version)
+		}
+		pkg.Tags = meta.Tags
+	}
+	return packages, nil
+}
+
+// formatVersion renders a published version, marking yanked ones
+func formatVersion(version RemoteVersion) string {
+	if version.Yanked {
+		return version.Version + " (yanked)"
+	}
+	return version.Version
+}
+
+// readRemoteManifest streams the manifest out of a published archive,
+// returning nil for archives created without one
+func readRemoteManifest(sshClient *ssh.Client, archive string) (*config.PacketConfig, error) {
+	remoteFile, err := sshClient.OpenFile(filepath.Join(sshClient.GetRemoteDir(), archive))
+	if err != nil {
+		return nil, err
+	}
+	defer remoteFile.Close()
+
+	data, err := utils.ReadTarGzEntry(remoteFile, manifestEntry)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read manifest of %s: %w", archive, err)
+	}
+
+	var manifest config.PacketConfig
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest of %s: %w", archive, err)
+	}
+	return &manifest, nil
+}
+
+// matchesTerm reports whether the name, description or keywords of a
+// package contain term, ignoring case
+func matchesTerm(pkg *RemotePackage, term string) bool {
+	term = strings.ToLower(term)
+	if strings.Contains(strings.ToLower(pkg.Name), term) ||
+		strings.Contains(strings.ToLower(pkg.Description), term) {
+		return true
+	}
+	for _, keyword := range pkg.Keywords {
+		if strings.Contains(strings.ToLower(keyword), term) {
+			return true
+		}
+	}
+	return false
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// RegistryList prints the packages published on the server with all their
+// versions, or only the versions of the named package
+func RegistryList(sshConfig config.SSHConfig, name string, jsonOutput bool) error {
+	sshClient, err := connectRegistry(sshConfig)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	packages, err := listRegistry(sshClient)
+	if err != nil {
+		return err
+	}
+	// Written to stderr to keep --json output parseable
+	if err := warnUnindexed(sshClient, os.Stderr); err != nil {
+		return err
+	}
+
+	if name != "" {
+		var found []*RemotePackage
+		for _, pkg := range packages {
+			if pkg.Name == name {
+				found = append(found, pkg)
+			}
+		}
+		if len(found) == 0 {
+			return fmt.Errorf("no packages found for %s", name)
+		}
+		packages = found
+	}
+
+	if jsonOutput {
+		return printJSON(packages)
+	}
+	if len(packages) == 0 {
+		fmt.Println("No packages published")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tSIZE\tPUBLISHED")
+	for _, pkg := range packages {
+		for _, version := range pkg.Versions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+				pkg.Name, formatVersion(version), progress.FormatSize(version.Size), version.Published.Local().Format("2006-01-02 15:04"))
+		}
+	}
+	return w.Flush()
+}
+
+// Search prints the published packages whose name, or description or
+// keywords in the manifest of their newest version, contain term
+func Search(sshConfig config.SSHConfig, term string, jsonOutput bool) error {
+	sshClient, err := connectRegistry(sshConfig)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	packages, err := listRegistry(sshClient)
+	if err != nil {
+		return err
+	}
+
+	matches := []*RemotePackage{}
+	for _, pkg := range packages {
+		manifest, err := readRemoteManifest(sshClient, pkg.Versions[0].Archive)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else if manifest != nil {
+			pkg.Description = manifest.Description
+			pkg.Keywords = manifest.Keywords
+		}
+
+		if matchesTerm(pkg, term) {
+			matches = append(matches, pkg)
+		}
+	}
+
+	if jsonOutput {
+		return printJSON(matches)
+	}
+	if len(matches) == 0 {
+		fmt.Printf("No packages match %q\n", term)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tLATEST\tDESCRIPTION")
+	for _, pkg := range matches {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", pkg.Name, pkg.Versions[0].Version, pkg.Description)
+	}
+	return w.Flush()
+}
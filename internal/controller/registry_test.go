@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeFileInfo is a remote directory entry
+type fakeFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (f fakeFileInfo) Name() string       { return f.name }
+func (f fakeFileInfo) Size() int64        { return f.size }
+func (f fakeFileInfo) Mode() os.FileMode  { return 0644 }
+func (f fakeFileInfo) ModTime() time.Time { return f.modTime }
+func (f fakeFileInfo) IsDir() bool        { return false }
+func (f fakeFileInfo) Sys() any           { return nil }
+
+func TestSplitArchiveName(t *testing.T) {
+	tests := []struct {
+		filename string
+		name     string
+		version  string
+		ok       bool
+	}{
+		{"foo-1.0.0.tar.gz", "foo", "1.0.0", true},
+		{"my-package-1.2.tar.gz", "my-package", "1.2", true},
+		{"foo-2-1.0.0.tar.gz", "foo-2", "1.0.0", true},
+		{"foo-latest.tar.gz", "", "", false},
+		{"foo-1.0.0.zip", "", "", false},
+		{"-1.0.0.tar.gz", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			name, version, ok := splitArchiveName(tt.filename)
+			if ok != tt.ok || name != tt.name || version.Raw != tt.version {
+				t.Errorf("splitArchiveName(%q) = %q, %q, %v, want %q, %q, %v",
+					tt.filename, name, version.Raw, ok, tt.name, tt.version, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGroupArchives(t *testing.T) {
+	now := time.Now()
+	files := []os.FileInfo{
+		fakeFileInfo{"foo-1.2.0.tar.gz", 10, now},
+		fakeFileInfo{"bar-1.0.0.tar.gz", 20, now},
+		fakeFileInfo{"foo-1.10.0.tar.gz", 30, now},
+		fakeFileInfo{"README.md", 1, now},
+		fakeFileInfo{"foo-1.9.0.tar.gz", 40, now},
+	}
+
+	packages := groupArchives(files)
+
+	var names []string
+	for _, pkg := range packages {
+		names = append(names, pkg.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"bar", "foo"}) {
+		t.Fatalf("groupArchives() packages = %v, want [bar foo]", names)
+	}
+
+	var versions []string
+	for _, version := range packages[1].Versions {
+		versions = append(versions, version.Version)
+	}
+	if !reflect.DeepEqual(versions, []string{"1.10.0", "1.9.0", "1.2.0"}) {
+		t.Errorf("foo versions = %v, want newest first", versions)
+	}
+	if packages[1].Versions[0].Size != 30 {
+		t.Errorf("foo 1.10.0 size = %d, want 30", packages[1].Versions[0].Size)
+	}
+}
+
+func TestMatchesTerm(t *testing.T) {
+	pkg := &RemotePackage{
+		Name:        "json-tools",
+		Description: "Utilities for Working with JSON",
+		Keywords:    []string{"parser", "cli"},
+	}
+
+	tests := []struct {
+		term string
+		want bool
+	}{
+		{"json", true},
+		{"working", true},
+		{"CLI", true},
+		{"yaml", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesTerm(pkg, tt.term); got != tt.want {
+			t.Errorf("matchesTerm(%q) = %v, want %v", tt.term, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/ssh"
+)
+
+// registryLockName is the lock file next to the published archives that
+// serializes uploads and changes to the index and registry metadata
+const registryLockName = ".lock"
+
+// registryLockPath returns the remote path of the registry lock
+func registryLockPath(sshClient *ssh.Client) string {
+	return filepath.Join(sshClient.GetRemoteDir(), registryLockName)
+}
+
+// lockRegistry takes the advisory write lock of the registry, waiting for
+// another writer to release it
+func lockRegistry(sshClient *ssh.Client) (*ssh.Lock, error) {
+	lock, err := sshClient.AcquireLock(registryLockPath(sshClient), 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock registry: %w", err)
+	}
+	return lock, nil
+}
+
+// Unlock removes the registry lock left behind by a writer that is gone.
+// Locks that have not expired are only removed with force.
+func Unlock(sshConfig config.SSHConfig, force bool) error {
+	sshClient, err := connectRegistry(sshConfig)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	lockPath := registryLockPath(sshClient)
+	holder, err := sshClient.ReadLock(lockPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Println("Registry is not locked")
+			return nil
+		}
+		return fmt.Errorf("failed to read registry lock: %w", err)
+	}
+
+	if !holder.Expired(time.Now()) && !force {
+		return fmt.Errorf("registry is locked by %s, which has not expired (use --force to remove it anyway)", holder)
+	}
+
+	if err := sshClient.ForceUnlock(lockPath); err != nil {
+		return err
+	}
+	fmt.Printf("Removed registry lock held by %s\n", holder)
+	return nil
+}
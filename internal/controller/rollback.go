@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/rasadov/package-manager/internal/state"
+	"github.com/rasadov/package-manager/internal/utils"
+)
+
+// RollbackOptions controls how a previous version is reinstalled
+type RollbackOptions struct {
+	// Prefix is the install root. When empty, the install_dir of the
+	// packages file or the default install root is used.
+	Prefix string
+	// PackagesPath is the packages file selecting the install root and the
+	// trusted packages. It is optional.
+	PackagesPath string
+	// AllowScripts runs the lifecycle scripts of the reinstalled version
+	AllowScripts bool
+}
+
+// Rollback reinstalls a previously installed version of a package from the
+// local store. Without a version, the most recent version installed before
+// the current one is used.
+func Rollback(name, version string, opts RollbackOptions) error {
+	packagesConfig, err := loadOptionalPackagesConfig(opts.PackagesPath)
+	if err != nil {
+		return err
+	}
+	db, err := state.Open(resolveInstallRoot(opts.Prefix, packagesConfig))
+	if err != nil {
+		return fmt.Errorf("failed to open state database: %w", err)
+	}
+	defer db.Close()
+
+	target, err := findRollbackTarget(db, name, version)
+	if err != nil {
+		return err
+	}
+
+	archivePath, ok := db.StoredArchive(name, target.Archive)
+	if !ok {
+		return fmt.Errorf("version %s of %s is no longer in the local store", target.Version, name)
+	}
+
+	// Make sure the stored archive was not altered since it was installed
+	if target.Checksum != "" {
+		checksum, err := utils.FileChecksum(archivePath)
+		if err != nil {
+			return fmt.Errorf("failed to checksum stored archive: %w", err)
+		}
+		if checksum != target.Checksum {
+			return fmt.Errorf("stored archive %s does not match its recorded checksum", target.Archive)
+		}
+	}
+
+	fmt.Printf("Rolling back %s to version %s...\n", name, target.Version)
+
+	// Reinstall into the directory the current version was installed to
+	dest := ""
+	if pkg, ok := db.Get(name); ok {
+		dest = pkg.InstallDir
+	}
+
+	tx := &transaction{}
+	allowed := opts.AllowScripts || (packagesConfig != nil && packagesConfig.IsTrusted(name))
+	if err := installArchive(db, tx, name, target.Version, dest, archivePath, allowed); err != nil {
+		return abortUpdate(tx, fmt.Errorf("failed to install package %s: %w", name, err))
+	}
+	recordHistory(db, name, state.ActionRollback)
+
+	if err := db.Save(); err != nil {
+		return abortUpdate(tx, fmt.Errorf("failed to save state database: %w", err))
+	}
+	tx.commit()
+
+	fmt.Printf("Package %s rolled back to version %s\n", name, target.Version)
+	return nil
+}
+
+// findRollbackTarget returns the history entry of the version to roll back to
+func findRollbackTarget(db *state.DB, name, version string) (state.HistoryEntry, error) {
+	current := ""
+	if pkg, ok := db.Get(name); ok {
+		current = pkg.Version
+	}
+
+	history := db.PackageHistory(name)
+	for i := len(history) - 1; i >= 0; i-- {
+		entry := history[i]
+		if entry.Action == state.ActionUninstall || entry.Archive == "" {
+			continue
+		}
+
+		if version == "" {
+			if entry.Version != current {
+				if _, ok := db.StoredArchive(name, entry.Archive); ok {
+					return entry, nil
+				}
+			}
+			continue
+		}
+
+		if entry.Version == version {
+			if entry.Version == current {
+				return state.HistoryEntry{}, fmt.Errorf("version %s of %s is already installed", version, name)
+			}
+			return entry, nil
+		}
+	}
+
+	if version == "" {
+		return state.HistoryEntry{}, fmt.Errorf("no previous version of %s is available in the local store", name)
+	}
+	return state.HistoryEntry{}, fmt.Errorf("version %s of %s was never installed", version, name)
+}
+
+// History prints the install history of a package in the install root
+// selected by prefix or the packages file at packagesPath
+func History(prefix, packagesPath, name string) error {
+	root, err := loadInstallRoot(prefix, packagesPath)
+	if err != nil {
+		return err
+	}
+	db, err := state.Load(root)
+	if err != nil {
+		return fmt.Errorf("failed to load state database: %w", err)
+	}
+
+	history := db.PackageHistory(name)
+	if len(history) == 0 {
+		return fmt.Errorf("no history recorded for %s", name)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tACTION\tVERSION\tSTORED")
+	for _, entry := range history {
+		stored := "-"
+		if entry.Archive != "" && entry.Action != state.ActionUninstall {
+			stored = "no"
+			if _, ok := db.StoredArchive(name, entry.Archive); ok {
+				stored = "yes"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Action, entry.Version, stored)
+	}
+	return w.Flush()
+}
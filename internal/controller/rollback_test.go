@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rasadov/package-manager/internal/state"
+)
+
+func TestFindRollbackTarget(t *testing.T) {
+	db, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	archives := t.TempDir()
+	for _, version := range []string{"1.0.0", "1.1.0", "1.2.0"} {
+		archive := filepath.Join(archives, "foo-"+version+".tar.gz")
+		os.WriteFile(archive, []byte(version), 0644)
+		if version != "1.0.0" {
+			db.StoreArchive("foo", archive)
+		}
+		db.Put(&state.Package{Name: "foo", Version: version, Archive: filepath.Base(archive)})
+		recordHistory(db, "foo", state.ActionInstall)
+	}
+
+	tests := []struct {
+		name        string
+		version     string
+		expected    string
+		expectError bool
+	}{
+		{name: "previous version", version: "", expected: "1.1.0"},
+		{name: "explicit version", version: "1.1.0", expected: "1.1.0"},
+		{name: "explicit version not stored", version: "1.0.0", expected: "1.0.0"},
+		{name: "current version", version: "1.2.0", expectError: true},
+		{name: "never installed", version: "9.9.9", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := findRollbackTarget(db, "foo", tt.version)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("findRollbackTarget() expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("findRollbackTarget() unexpected error: %v", err)
+				return
+			}
+
+			if entry.Version != tt.expected {
+				t.Errorf("findRollbackTarget() = %s, want %s", entry.Version, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFindRollbackTargetSkipsUnstoredVersions(t *testing.T) {
+	db, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		db.Put(&state.Package{Name: "foo", Version: version, Archive: "foo-" + version + ".tar.gz"})
+		recordHistory(db, "foo", state.ActionInstall)
+	}
+
+	if _, err := findRollbackTarget(db, "foo", ""); err == nil {
+		t.Errorf("findRollbackTarget() expected error when no previous version is stored")
+	}
+}
+
+func TestRollbackRestoresOnFailingPostinstall(t *testing.T) {
+	root := t.TempDir()
+	archives := t.TempDir()
+
+	db, err := state.Open(root)
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+
+	// Version 1.0.0 has a failing postinstall that did not run on install
+	v1 := filepath.Join(archives, "foo-1.0.0.tar.gz")
+	writeTestArchive(t, v1, map[string]string{
+		".pm/scripts/postinstall": "exit 3",
+		"tool":                    "tool v1",
+	})
+	v2 := filepath.Join(archives, "foo-2.0.0.tar.gz")
+	writeTestArchive(t, v2, map[string]string{"tool": "tool v2"})
+
+	for _, install := range []struct{ version, archive string }{{"1.0.0", v1}, {"2.0.0", v2}} {
+		tx := &transaction{}
+		if err := installArchive(db, tx, "foo", install.version, "", install.archive, false); err != nil {
+			t.Fatalf("installArchive(%s) error = %v", install.version, err)
+		}
+		tx.commit()
+		recordHistory(db, "foo", state.ActionInstall)
+	}
+	if err := db.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	db.Close()
+
+	if err := Rollback("foo", "1.0.0", RollbackOptions{Prefix: root, AllowScripts: true}); err == nil {
+		t.Fatalf("Rollback() expected error from failing postinstall")
+	}
+
+	if got := readFileString(t, filepath.Join(root, "foo/tool")); got != "tool v2" {
+		t.Errorf("foo/tool = %q, want the previous version restored", got)
+	}
+	entries, _ := os.ReadDir(root)
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".backup-") {
+			t.Errorf("rollback left %s behind", entry.Name())
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/utils"
+)
+
+// Lifecycle hooks
+const (
+	hookPreinstall   = "preinstall"
+	hookPostinstall  = "postinstall"
+	hookPreuninstall = "preuninstall"
+)
+
+// manifestEntry is the archive path of the embedded packet manifest
+var manifestEntry = path.Join(utils.MetadataDir, "packet.json")
+
+// scriptEntry returns the archive path of a lifecycle script
+func scriptEntry(hook string) string {
+	return path.Join(utils.MetadataDir, "scripts", hook)
+}
+
+// packageMetadata returns the archive entries embedding the packet manifest
+// and its lifecycle scripts
+func packageMetadata(packetConfig *config.PacketConfig) ([]utils.ArchiveEntry, error) {
+	manifest, err := json.MarshalIndent(packetConfig, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	entries := []utils.ArchiveEntry{{Name: manifestEntry, Data: manifest, Mode: 0644}}
+
+	if packetConfig.Scripts == nil {
+		return entries, nil
+	}
+
+	scripts := []struct {
+		hook string
+		path string
+	}{
+		{hookPreinstall, packetConfig.Scripts.Preinstall},
+		{hookPostinstall, packetConfig.Scripts.Postinstall},
+		{hookPreuninstall, packetConfig.Scripts.Preuninstall},
+	}
+	for _, script := range scripts {
+		if script.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(script.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s script: %w", script.hook, err)
+		}
+		entries = append(entries, utils.ArchiveEntry{Name: scriptEntry(script.hook), Data: data, Mode: 0755})
+	}
+
+	return entries, nil
+}
+
+// runHook runs a lifecycle script embedded in a package archive, if there is
+// one. Scripts only run when allowed; otherwise they are skipped with a warning.
+func runHook(archivePath, hook string, allowed bool, name, version, installDir string) error {
+	script, err := utils.ReadTarGzFile(archivePath, scriptEntry(hook))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s script: %w", hook, err)
+	}
+
+	if !allowed {
+		fmt.Printf("Warning: skipping %s script of %s (pass --allow-scripts or add it to trusted_packages)\n", hook, name)
+		return nil
+	}
+
+	scriptFile, err := os.CreateTemp("", "pm-"+hook+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create script file: %w", err)
+	}
+	defer os.Remove(scriptFile.Name())
+	if _, err := scriptFile.Write(script); err != nil {
+		scriptFile.Close()
+		return fmt.Errorf("failed to write script file: %w", err)
+	}
+	scriptFile.Close()
+
+	absInstallDir, err := filepath.Abs(installDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve install directory: %w", err)
+	}
+
+	// Scripts run in the install directory, or its parent before a first install
+	workDir := absInstallDir
+	if _, err := os.Stat(workDir); err != nil {
+		workDir = filepath.Dir(workDir)
+	}
+
+	fmt.Printf("Running %s script of %s...\n", hook, name)
+	cmd := exec.Command("sh", scriptFile.Name())
+	cmd.Dir = workDir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"PM_HOOK="+hook,
+		"PM_PACKAGE_NAME="+name,
+		"PM_PACKAGE_VERSION="+version,
+		"PM_INSTALL_DIR="+absInstallDir,
+	)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s script failed: %w", hook, err)
+	}
+
+	return nil
+}
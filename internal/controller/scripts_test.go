@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/state"
+	"github.com/rasadov/package-manager/internal/utils"
+)
+
+func TestPackageMetadata(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), "post.sh")
+	os.WriteFile(scriptPath, []byte("echo done"), 0644)
+
+	packetConfig := &config.PacketConfig{
+		Name:    "foo",
+		Version: "1.0.0",
+		Scripts: &config.PacketScripts{Postinstall: scriptPath},
+	}
+
+	entries, err := packageMetadata(packetConfig)
+	if err != nil {
+		t.Fatalf("packageMetadata() error = %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected manifest and one script, got %d entries", len(entries))
+	}
+	if entries[0].Name != ".pm/packet.json" || !strings.Contains(string(entries[0].Data), `"name": "foo"`) {
+		t.Errorf("unexpected manifest entry: %s %s", entries[0].Name, entries[0].Data)
+	}
+	if entries[1].Name != ".pm/scripts/postinstall" || string(entries[1].Data) != "echo done" {
+		t.Errorf("unexpected script entry: %s %s", entries[1].Name, entries[1].Data)
+	}
+
+	packetConfig.Scripts.Preinstall = filepath.Join(t.TempDir(), "missing.sh")
+	if _, err := packageMetadata(packetConfig); err == nil {
+		t.Errorf("packageMetadata() expected error for missing script")
+	}
+}
+
+func TestRunHook(t *testing.T) {
+	archives := t.TempDir()
+	installDir := t.TempDir()
+	outPath := filepath.Join(t.TempDir(), "hook.out")
+
+	archive := filepath.Join(archives, "foo-1.0.0.tar.gz")
+	writeTestArchive(t, archive, map[string]string{
+		".pm/scripts/postinstall": `echo "$PM_HOOK $PM_PACKAGE_NAME $PM_PACKAGE_VERSION $PM_INSTALL_DIR" > "` + outPath + `"`,
+		"bin/tool":                "tool",
+	})
+
+	// Scripts are skipped unless allowed
+	if err := runHook(archive, hookPostinstall, false, "foo", "1.0.0", installDir); err != nil {
+		t.Fatalf("runHook() error = %v", err)
+	}
+	if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+		t.Fatalf("script ran without being allowed")
+	}
+
+	if err := runHook(archive, hookPostinstall, true, "foo", "1.0.0", installDir); err != nil {
+		t.Fatalf("runHook() error = %v", err)
+	}
+	expected := "postinstall foo 1.0.0 " + installDir + "\n"
+	if got := readFileString(t, outPath); got != expected {
+		t.Errorf("script output = %q, want %q", got, expected)
+	}
+
+	// Packages without the hook are fine
+	if err := runHook(archive, hookPreinstall, true, "foo", "1.0.0", installDir); err != nil {
+		t.Errorf("runHook() for missing hook error = %v", err)
+	}
+}
+
+func TestInstallArchiveFailingPostinstall(t *testing.T) {
+	root := t.TempDir()
+	archives := t.TempDir()
+
+	db, err := state.Open(root)
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	archive := filepath.Join(archives, "foo-1.0.0.tar.gz")
+	writeTestArchive(t, archive, map[string]string{
+		".pm/scripts/postinstall": "exit 3",
+		"bin/tool":                "tool",
+	})
+
+	tx := &transaction{}
+	if err := installArchive(db, tx, "foo", "1.0.0", "", archive, true); err == nil {
+		t.Fatalf("installArchive() expected error from failing postinstall")
+	}
+	if _, ok := db.Get("foo"); ok {
+		t.Errorf("package recorded despite failing postinstall")
+	}
+
+	if err := tx.rollback(); err != nil {
+		t.Fatalf("rollback() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "foo")); !os.IsNotExist(err) {
+		t.Errorf("rollback left the package installed")
+	}
+	if _, err := os.Stat(filepath.Join(root, "foo", utils.MetadataDir)); !os.IsNotExist(err) {
+		t.Errorf("package metadata must not be extracted")
+	}
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/rasadov/package-manager/config"
+)
+
+// validateTag rejects tag names that could be mistaken for versions
+func validateTag(tag string) error {
+	if tag == "" || !validPrerelease(tag) {
+		return fmt.Errorf("invalid tag %q, use letters, digits, dashes and dots", tag)
+	}
+	if _, err := parseVersion(tag); err == nil {
+		return fmt.Errorf("invalid tag %q, tags must not look like versions", tag)
+	}
+	return nil
+}
+
+// Tag points a distribution tag of a package at a published version
+func Tag(sshConfig config.SSHConfig, spec, tag string) error {
+	if err := validateTag(tag); err != nil {
+		return err
+	}
+
+	sshClient, err := connectRegistry(sshConfig)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	request, _, err := resolvePublished(sshClient, spec)
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockRegistry(sshClient)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	meta, err := loadPackageMeta(sshClient, request.Name)
+	if err != nil {
+		return err
+	}
+	if meta.IsYanked(request.Version) {
+		return fmt.Errorf("%s %s is yanked and cannot be tagged", request.Name, request.Version)
+	}
+
+	previous := meta.setTag(tag, request.Version)
+	if previous == request.Version {
+		fmt.Printf("%s %s is already tagged %s\n", request.Name, request.Version, tag)
+		return nil
+	}
+	meta.addEvent(actionTag, request.Version, sshConfig.Username)
+
+	if err := savePackageMeta(sshClient, request.Name, meta); err != nil {
+		return err
+	}
+
+	if previous != "" {
+		fmt.Printf("Moved tag %s of %s from %s to %s\n", tag, request.Name, previous, request.Version)
+	} else {
+		fmt.Printf("Tagged %s %s as %s\n", request.Name, request.Version, tag)
+	}
+	return nil
+}
+
+// Untag removes a distribution tag of a package
+func Untag(sshConfig config.SSHConfig, name, tag string) error {
+	sshClient, err := connectRegistry(sshConfig)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	lock, err := lockRegistry(sshClient)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	meta, err := loadPackageMeta(sshClient, name)
+	if err != nil {
+		return err
+	}
+	version, ok := meta.Tags[tag]
+	if !ok {
+		return fmt.Errorf("%s has no tag %s", name, tag)
+	}
+
+	delete(meta.Tags, tag)
+	meta.addEvent(actionUntag, version, sshConfig.Username)
+	if err := savePackageMeta(sshClient, name, meta); err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed tag %s of %s\n", tag, name)
+	return nil
+}
+
+// ListTags prints the distribution tags of a package
+func ListTags(sshConfig config.SSHConfig, name string) error {
+	sshClient, err := connectRegistry(sshConfig)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	meta, err := loadPackageMeta(sshClient, name)
+	if err != nil {
+		return err
+	}
+	if len(meta.Tags) == 0 {
+		fmt.Printf("%s has no tags\n", name)
+		return nil
+	}
+
+	tags := make([]string, 0, len(meta.Tags))
+	for tag := range meta.Tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TAG\tVERSION")
+	for _, tag := range tags {
+		fmt.Fprintf(w, "%s\t%s\n", tag, meta.Tags[tag])
+	}
+	return w.Flush()
+}
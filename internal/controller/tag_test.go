@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/rasadov/package-manager/config"
+)
+
+func TestValidateTag(t *testing.T) {
+	tests := []struct {
+		tag     string
+		wantErr bool
+	}{
+		{"stable", false},
+		{"beta-2", false},
+		{"", true},
+		{"1.0", true},
+		{"has space", true},
+	}
+
+	for _, tt := range tests {
+		if err := validateTag(tt.tag); (err != nil) != tt.wantErr {
+			t.Errorf("validateTag(%q) error = %v, wantErr %v", tt.tag, err, tt.wantErr)
+		}
+	}
+}
+
+func TestResolveTag(t *testing.T) {
+	var candidates []PackageCandidate
+	for _, raw := range []string{"1.0.0", "1.1.0", "2.0.0-beta.1"} {
+		version, err := parseVersion(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		candidates = append(candidates, PackageCandidate{Filename: "foo-" + raw + ".tar.gz", Version: version})
+	}
+
+	meta := &PackageMeta{}
+	if previous := meta.setTag("stable", "1.0.0"); previous != "" {
+		t.Errorf("setTag() previous = %q, want empty", previous)
+	}
+	meta.setTag("beta", "2.0.0-beta.1")
+	meta.setTag("old", "0.9.0")
+
+	tests := []struct {
+		name       string
+		tag        string
+		constraint string
+		expected   string
+		wantErr    bool
+	}{
+		{"release", "stable", "", "1.0.0", false},
+		{"pre-release", "beta", "", "2.0.0-beta.1", false},
+		{"matching constraint", "stable", ">=1.0.0", "1.0.0", false},
+		{"constraint mismatch", "stable", ">=1.1.0", "", true},
+		{"unpublished version", "old", "", "", true},
+		{"missing tag", "nightly", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg := config.PackageRequest{Name: "foo", Version: tt.constraint, Tag: tt.tag}
+			selected, err := resolveTag(candidates, meta, pkg, tt.tag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveTag() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && selected.Version.Raw != tt.expected {
+				t.Errorf("resolveTag() = %s, want %s", selected.Version.Raw, tt.expected)
+			}
+		})
+	}
+
+	if previous := meta.setTag("stable", "1.1.0"); previous != "1.0.0" {
+		t.Errorf("setTag() previous = %q, want 1.0.0", previous)
+	}
+}
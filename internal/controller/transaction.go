@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// swap records a target that was replaced during a transaction
+type swap struct {
+	target string
+	// backup holds the previous content of target, empty if target did not exist
+	backup string
+}
+
+// transaction swaps staged files and directories into place and can
+// restore the previous content of every target if a later step fails
+type transaction struct {
+	swaps []swap
+}
+
+// swapIn atomically replaces target with staging. The previous content of
+// target is moved aside so the transaction can roll it back.
+func (tx *transaction) swapIn(staging, target string) error {
+	s := swap{target: target}
+
+	if _, err := os.Lstat(target); err == nil {
+		s.backup = siblingPath(target, "backup")
+		if err := os.Rename(target, s.backup); err != nil {
+			return fmt.Errorf("failed to move %s aside: %w", target, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to stat %s: %w", target, err)
+	}
+
+	if err := os.Rename(staging, target); err != nil {
+		// Put the previous content back before reporting the failure
+		if s.backup != "" {
+			os.Rename(s.backup, target)
+		}
+		return fmt.Errorf("failed to move %s into place: %w", target, err)
+	}
+
+	tx.swaps = append(tx.swaps, s)
+	return nil
+}
+
+// moveAside removes target from its place, keeping it so the transaction
+// can restore it on rollback
+func (tx *transaction) moveAside(target string) error {
+	backup := siblingPath(target, "backup")
+	if err := os.Rename(target, backup); err != nil {
+		return fmt.Errorf("failed to move %s aside: %w", target, err)
+	}
+
+	tx.swaps = append(tx.swaps, swap{target: target, backup: backup})
+	return nil
+}
+
+// rollback restores every swapped target in reverse order
+func (tx *transaction) rollback() error {
+	var firstErr error
+	for i := len(tx.swaps) - 1; i >= 0; i-- {
+		s := tx.swaps[i]
+
+		if err := os.RemoveAll(s.target); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to remove %s: %w", s.target, err)
+			continue
+		}
+		if s.backup != "" {
+			if err := os.Rename(s.backup, s.target); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to restore %s: %w", s.target, err)
+			}
+		}
+	}
+	tx.swaps = nil
+	return firstErr
+}
+
+// commit discards the backups kept for rollback
+func (tx *transaction) commit() {
+	for _, s := range tx.swaps {
+		if s.backup != "" {
+			os.RemoveAll(s.backup)
+		}
+	}
+	tx.swaps = nil
+}
+
+// siblingPath returns an unused hidden path next to target, such as
+// "dir/.name.backup-123456"
+func siblingPath(target, kind string) string {
+	dir, name := filepath.Split(target)
+	for i := 0; ; i++ {
+		candidate := filepath.Join(dir, fmt.Sprintf(".%s.%s-%d-%d", name, kind, os.Getpid(), i))
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFileString(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+// makeDir creates dir containing a single file with the given content
+func makeDir(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "foo")
+	created := filepath.Join(root, "bar")
+	makeDir(t, existing, "old foo")
+
+	tx := &transaction{}
+
+	stagingFoo := filepath.Join(root, ".foo.staging")
+	makeDir(t, stagingFoo, "new foo")
+	if err := tx.swapIn(stagingFoo, existing); err != nil {
+		t.Fatalf("swapIn() error = %v", err)
+	}
+
+	stagingBar := filepath.Join(root, ".bar.staging")
+	makeDir(t, stagingBar, "new bar")
+	if err := tx.swapIn(stagingBar, created); err != nil {
+		t.Fatalf("swapIn() error = %v", err)
+	}
+
+	if got := readFileString(t, filepath.Join(existing, "file.txt")); got != "new foo" {
+		t.Errorf("after swapIn foo = %q, want %q", got, "new foo")
+	}
+
+	if err := tx.rollback(); err != nil {
+		t.Fatalf("rollback() error = %v", err)
+	}
+
+	if got := readFileString(t, filepath.Join(existing, "file.txt")); got != "old foo" {
+		t.Errorf("after rollback foo = %q, want %q", got, "old foo")
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("newly installed bar should have been removed by rollback")
+	}
+
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 1 {
+		t.Errorf("rollback left extra entries in root: %v", entries)
+	}
+}
+
+func TestTransactionCommit(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "foo")
+	makeDir(t, target, "old")
+
+	staging := filepath.Join(root, ".foo.staging")
+	makeDir(t, staging, "new")
+
+	tx := &transaction{}
+	if err := tx.swapIn(staging, target); err != nil {
+		t.Fatalf("swapIn() error = %v", err)
+	}
+	tx.commit()
+
+	if got := readFileString(t, filepath.Join(target, "file.txt")); got != "new" {
+		t.Errorf("after commit foo = %q, want %q", got, "new")
+	}
+
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 1 {
+		t.Errorf("commit should remove backups, root contains %v", entries)
+	}
+}
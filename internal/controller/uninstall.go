@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/state"
+)
+
+// UninstallOptions controls how a package is removed
+type UninstallOptions struct {
+	// Prefix is the install root. When empty, the install_dir of the
+	// packages file or the default install root is used.
+	Prefix string
+	// PackagesPath is the packages file whose install_dir selects the
+	// install root. It is not read when empty.
+	PackagesPath string
+	// Save removes the package entry from the packages file
+	Save bool
+	// Force removes files even if they were modified since install
+	Force bool
+	// AllowScripts runs the preuninstall script of the package
+	AllowScripts bool
+}
+
+// Uninstall removes the files installed by a package
+func Uninstall(name string, opts UninstallOptions) error {
+	packagesConfig, err := loadOptionalPackagesConfig(opts.PackagesPath)
+	if err != nil {
+		return err
+	}
+	if opts.Save && packagesConfig == nil {
+		return fmt.Errorf("no packages file to remove %s from", name)
+	}
+
+	db, err := state.Open(resolveInstallRoot(opts.Prefix, packagesConfig))
+	if err != nil {
+		return fmt.Errorf("failed to open state database: %w", err)
+	}
+	defer db.Close()
+
+	pkg, ok := db.Get(name)
+	if !ok {
+		return fmt.Errorf("package %s is not installed", name)
+	}
+
+	fmt.Printf("Uninstalling %s (version %s)...\n", pkg.Name, pkg.Version)
+
+	// The stored archive of the installed version holds its scripts
+	if archivePath, ok := db.StoredArchive(pkg.Name, pkg.Archive); ok {
+		allowed := opts.AllowScripts || (packagesConfig != nil && packagesConfig.IsTrusted(name))
+		installDir := filepath.Join(db.Root(), filepath.FromSlash(pkg.InstallDir))
+		if err := runHook(archivePath, hookPreuninstall, allowed, pkg.Name, pkg.Version, installDir); err != nil {
+			return err
+		}
+	}
+
+	if err := removeBins(db.Root(), pkg.Bins); err != nil {
+		return err
+	}
+	if err := removePending(db.Root(), pkg.Pending); err != nil {
+		return err
+	}
+
+	modified, err := removeInstalledFiles(db.Root(), pkg.Files, opts.Force)
+	if err != nil {
+		return fmt.Errorf("failed to remove package files: %w", err)
+	}
+	for _, path := range modified {
+		if opts.Force {
+			fmt.Printf("Warning: removed %s, which was modified since install\n", path)
+		} else {
+			fmt.Printf("Warning: kept %s, which was modified since install\n", path)
+		}
+	}
+
+	recordHistory(db, name, state.ActionUninstall)
+	db.Remove(name)
+	if err := db.Save(); err != nil {
+		return fmt.Errorf("failed to save state database: %w", err)
+	}
+
+	// Remove the package from the packages file
+	if opts.Save {
+		if packagesConfig.RemovePackage(name) {
+			if err := config.SavePackagesConfig(opts.PackagesPath, packagesConfig); err != nil {
+				return fmt.Errorf("failed to save packages config: %w", err)
+			}
+			fmt.Printf("Removed %s from %s\n", name, opts.PackagesPath)
+		}
+	}
+
+	fmt.Printf("Package %s uninstalled successfully\n", name)
+	return nil
+}
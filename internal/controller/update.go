@@ -2,13 +2,52 @@ package controller
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/cache"
+	"github.com/rasadov/package-manager/internal/progress"
 	"github.com/rasadov/package-manager/internal/ssh"
+	"github.com/rasadov/package-manager/internal/state"
 )
 
+// UpdateOptions controls how packages are installed
+type UpdateOptions struct {
+	// Prefix overrides the install root set in the packages file
+	Prefix string
+	// Offline installs from the local cache and lockfile only
+	Offline bool
+	// Force reinstalls packages whose selected version is already installed
+	Force bool
+	// AllowScripts runs the lifecycle scripts of every package
+	AllowScripts bool
+	// Channel resolves packages without a tag through this distribution tag
+	Channel string
+	// Jobs is the number of packages downloaded in parallel
+	Jobs int
+}
+
+// updateRun holds the state shared by the packages of a single update
+type updateRun struct {
+	opts           UpdateOptions
+	packagesConfig *config.PackagesConfig
+	sshClient      *ssh.Client
+	// board shows the progress of downloads
+	board *progress.Board
+	// index is the package index of the registry, nil if it has none
+	index *RegistryIndex
+	// meta holds the registry metadata of the requested packages
+	meta     map[string]*PackageMeta
+	cache    *cache.Cache
+	lockfile *config.Lockfile
+	db       *state.DB
+	tx       *transaction
+}
+
 // Update downloads and installs packages based on packages configuration
-func Update(packagesPath string, sshConfig config.SSHConfig) error {
+func Update(packagesPath string, sshConfig config.SSHConfig, opts UpdateOptions) error {
 	// Load packages configuration
 	packagesConfig, err := config.LoadPackagesConfig(packagesPath)
 	if err != nil {
@@ -16,26 +55,200 @@ func Update(packagesPath string, sshConfig config.SSHConfig) error {
 	}
 
 	fmt.Printf("Updating %d packages...\n", len(packagesConfig.Packages))
+	if opts.Jobs <= 0 {
+		opts.Jobs = DefaultJobs
+	}
+
+	// Load lockfile and download cache
+	lockfilePath := config.LockfilePath(packagesPath)
+	lockfile, err := config.LoadLockfile(lockfilePath)
+	if err != nil {
+		return fmt.Errorf("failed to load lockfile: %w", err)
+	}
+
+	archiveCache, err := openCache()
+	if err != nil {
+		return fmt.Errorf("failed to open download cache: %w", err)
+	}
 
 	// Connect to SSH server
-	sshClient := ssh.NewClient(sshConfig)
-	if err := sshClient.Connect(); err != nil {
-		return fmt.Errorf("failed to connect to SSH server: %w", err)
+	var sshClient *ssh.Client
+	if !opts.Offline {
+		sshClient = ssh.NewClient(sshConfig)
+		if err := sshClient.Connect(); err != nil {
+			return fmt.Errorf("failed to connect to SSH server: %w", err)
+		}
+		defer sshClient.Close()
 	}
-	defer sshClient.Close()
 
-	// Process each package
-	for _, pkg := range packagesConfig.Packages {
-		fmt.Printf("Processing package: %s\n", pkg.Name)
+	// Fetch the package index and metadata once for all packages
+	var index *RegistryIndex
+	var meta map[string]*PackageMeta
+	if !opts.Offline {
+		index, err = loadIndex(sshClient)
+		if err != nil {
+			return err
+		}
+		if index == nil {
+			fmt.Println("Registry has no package index, listing archives instead")
+		}
 
-		if err := downloadAndInstallPackage(sshClient, pkg); err != nil {
-			fmt.Printf("Warning: Failed to install package %s: %v\n", pkg.Name, err)
+		names := make([]string, 0, len(packagesConfig.Packages))
+		for _, pkg := range packagesConfig.Packages {
+			names = append(names, pkg.Name)
+		}
+		if meta, err = loadRegistryMeta(sshClient, names); err != nil {
+			return err
+		}
+	}
+
+	// Open installed-package database
+	db, err := state.Open(resolveInstallRoot(opts.Prefix, packagesConfig))
+	if err != nil {
+		return fmt.Errorf("failed to open state database: %w", err)
+	}
+	defer db.Close()
+
+	tx := &transaction{}
+	run := &updateRun{
+		opts:           opts,
+		packagesConfig: packagesConfig,
+		sshClient:      sshClient,
+		board:          progress.NewBoard(os.Stdout),
+		index:          index,
+		meta:           meta,
+		cache:          archiveCache,
+		lockfile:       lockfile,
+		db:             db,
+		tx:             tx,
+	}
+
+	// Download and stage the packages in parallel. Installing them stays
+	// serial and in order, so the result does not depend on --jobs.
+	var fetched []*fetchedPackage
+	var fetchTime time.Duration
+	if !opts.Offline {
+		start := time.Now()
+		fetched, err = run.fetchPackages(packagesConfig.Packages)
+		if err != nil {
+			return err
+		}
+		defer cleanupFetched(fetched)
+		fetchTime = time.Since(start)
+	}
+
+	// Install each package. The update is all-or-nothing: if any package
+	// fails, every package already installed in this run is restored.
+	for i, pkg := range packagesConfig.Packages {
+		var installed bool
+		if opts.Offline {
+			fmt.Printf("Processing package: %s\n", pkg.Name)
+			installed, err = run.installFromCache(pkg)
+		} else {
+			installed, err = run.installFetched(pkg, fetched[i])
+		}
+		if err != nil {
+			return abortUpdate(tx, fmt.Errorf("failed to install package %s: %w", pkg.Name, err))
+		}
+
+		if !installed {
+			record, _ := db.Get(pkg.Name)
+			fmt.Printf("Package %s is up to date (version %s)\n", pkg.Name, record.Version)
 			continue
 		}
 
+		recordHistory(db, pkg.Name, state.ActionInstall)
 		fmt.Printf("Package %s installed successfully\n", pkg.Name)
 	}
 
+	if err := db.Save(); err != nil {
+		return abortUpdate(tx, fmt.Errorf("failed to save state database: %w", err))
+	}
+	tx.commit()
+
+	if err := archiveCache.Save(); err != nil {
+		fmt.Printf("Warning: failed to save download cache: %v\n", err)
+	}
+
+	// Pin the installed versions
+	if err := config.SaveLockfile(lockfilePath, newLockfile(db, packagesConfig)); err != nil {
+		return fmt.Errorf("failed to save lockfile: %w", err)
+	}
+
+	// Drop old versions from the local store
+	keepVersions := packagesConfig.KeepVersions
+	if keepVersions <= 0 {
+		keepVersions = defaultKeepVersions
+	}
+	for _, pkg := range packagesConfig.Packages {
+		if err := db.PruneStore(pkg.Name, keepVersions); err != nil {
+			fmt.Printf("Warning: failed to prune stored versions of %s: %v\n", pkg.Name, err)
+		}
+	}
+
+	printTransfers(packagesConfig.Packages, fetched, fetchTime)
 	fmt.Println("Package update completed!")
 	return nil
 }
+
+// scriptsAllowed reports whether the lifecycle scripts of a package may run
+func (r *updateRun) scriptsAllowed(name string) bool {
+	return r.opts.AllowScripts || r.packagesConfig.IsTrusted(name)
+}
+
+// isUpToDate reports whether the archive with the given checksum is already
+// installed at the requested destination with all of its files present.
+// It is always false with --force.
+func (r *updateRun) isUpToDate(pkg config.PackageRequest, archive, checksum string) bool {
+	if r.opts.Force {
+		return false
+	}
+
+	installed, ok := r.db.Get(pkg.Name)
+	if !ok {
+		return false
+	}
+
+	dest := pkg.Dest
+	if dest == "" {
+		dest = pkg.Name
+	}
+	if installed.Archive != archive ||
+		installed.Checksum != checksum ||
+		installed.InstallDir != filepath.ToSlash(filepath.Clean(dest)) {
+		return false
+	}
+
+	// Reinstall if installed files were deleted
+	for _, file := range installed.Files {
+		if _, err := os.Lstat(filepath.Join(r.db.Root(), filepath.FromSlash(file.Path))); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// newLockfile pins the installed version of every requested package
+func newLockfile(db *state.DB, packagesConfig *config.PackagesConfig) *config.Lockfile {
+	lockfile := &config.Lockfile{}
+	for _, pkg := range packagesConfig.Packages {
+		if record, ok := db.Get(pkg.Name); ok {
+			lockfile.Packages = append(lockfile.Packages, config.LockedPackage{
+				Name:     record.Name,
+				Version:  record.Version,
+				Archive:  record.Archive,
+				Checksum: record.Checksum,
+			})
+		}
+	}
+	return lockfile
+}
+
+// abortUpdate rolls back the packages installed so far and returns err
+func abortUpdate(tx *transaction, err error) error {
+	fmt.Println("Rolling back installed packages...")
+	if rollbackErr := tx.rollback(); rollbackErr != nil {
+		return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/state"
+)
+
+func TestIsUpToDate(t *testing.T) {
+	root := t.TempDir()
+	db, err := state.Open(root)
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	files := writeInstalledFiles(t, root, map[string]string{"foo/bin/tool": "tool"})
+	db.Put(&state.Package{
+		Name:       "foo",
+		Version:    "1.0.0",
+		Archive:    "foo-1.0.0.tar.gz",
+		Checksum:   "abc",
+		InstallDir: "foo",
+		Files:      files,
+	})
+
+	run := &updateRun{db: db}
+	foo := config.PackageRequest{Name: "foo"}
+
+	tests := []struct {
+		name     string
+		pkg      config.PackageRequest
+		archive  string
+		checksum string
+		force    bool
+		expected bool
+	}{
+		{name: "same archive", pkg: foo, archive: "foo-1.0.0.tar.gz", checksum: "abc", expected: true},
+		{name: "forced", pkg: foo, archive: "foo-1.0.0.tar.gz", checksum: "abc", force: true, expected: false},
+		{name: "new version", pkg: foo, archive: "foo-1.1.0.tar.gz", checksum: "def", expected: false},
+		{name: "republished archive", pkg: foo, archive: "foo-1.0.0.tar.gz", checksum: "def", expected: false},
+		{name: "new destination", pkg: config.PackageRequest{Name: "foo", Dest: "bin"}, archive: "foo-1.0.0.tar.gz", checksum: "abc", expected: false},
+		{name: "not installed", pkg: config.PackageRequest{Name: "bar"}, archive: "bar-1.0.0.tar.gz", checksum: "abc", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run.opts.Force = tt.force
+			if got := run.isUpToDate(tt.pkg, tt.archive, tt.checksum); got != tt.expected {
+				t.Errorf("isUpToDate() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	run.opts.Force = false
+	os.Remove(filepath.Join(root, "foo/bin/tool"))
+	if run.isUpToDate(foo, "foo-1.0.0.tar.gz", "abc") {
+		t.Errorf("isUpToDate() = true with installed files missing")
+	}
+}
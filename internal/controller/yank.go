@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/ssh"
+)
+
+// resolvePublished returns the archive name of the published version named
+// by spec, given as name@version
+func resolvePublished(sshClient *ssh.Client, spec string) (config.PackageRequest, string, error) {
+	request, err := parsePackageSpec(spec)
+	if err != nil {
+		return request, "", err
+	}
+	if _, err := parseVersion(request.Version); err != nil {
+		return request, "", fmt.Errorf("expected name@version with an exact version, got %s", spec)
+	}
+
+	archiveName := fmt.Sprintf("%s-%s.tar.gz", request.Name, request.Version)
+	exists, err := sshClient.FileExists(filepath.Join(sshClient.GetRemoteDir(), archiveName))
+	if err != nil {
+		return request, "", err
+	}
+	if !exists {
+		return request, "", fmt.Errorf("%s is not published", archiveName)
+	}
+	return request, archiveName, nil
+}
+
+// Yank marks a published version as not selectable for new resolutions.
+// Lockfiles that already pin it keep installing it. With undo the version
+// becomes selectable again. Versions that a distribution tag points at are
+// refused, since the tag would no longer resolve.
+func Yank(sshConfig config.SSHConfig, spec string, undo bool) error {
+	sshClient, err := connectRegistry(sshConfig)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	request, _, err := resolvePublished(sshClient, spec)
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockRegistry(sshClient)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	meta, err := loadPackageMeta(sshClient, request.Name)
+	if err != nil {
+		return err
+	}
+
+	action := actionYank
+	if undo {
+		action = actionUnyank
+	}
+	changed, err := yankVersion(meta, request.Name, request.Version, undo)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		if undo {
+			fmt.Printf("%s %s is not yanked\n", request.Name, request.Version)
+		} else {
+			fmt.Printf("%s %s is already yanked\n", request.Name, request.Version)
+		}
+		return nil
+	}
+	meta.addEvent(action, request.Version, sshConfig.Username)
+
+	if err := savePackageMeta(sshClient, request.Name, meta); err != nil {
+		return err
+	}
+
+	if undo {
+		fmt.Printf("Restored %s %s\n", request.Name, request.Version)
+	} else {
+		fmt.Printf("Yanked %s %s\n", request.Name, request.Version)
+	}
+	return nil
+}
+
+// yankVersion marks version as yanked, or not with undo, and reports
+// whether that changed the metadata. Tagged versions cannot be yanked.
+func yankVersion(meta *PackageMeta, name, version string, undo bool) (bool, error) {
+	if !undo {
+		if err := checkUntagged(meta, name, version); err != nil {
+			return false, err
+		}
+	}
+	return meta.setYanked(version, !undo), nil
+}
+
+// checkUntagged fails if a distribution tag points at version
+func checkUntagged(meta *PackageMeta, name, version string) error {
+	if tags := versionTags(meta.Tags, version); len(tags) > 0 {
+		return fmt.Errorf("%s %s is tagged %s; point the tag elsewhere or remove it with pm tag --delete %s <tag> first",
+			name, version, strings.Join(tags, ", "), name)
+	}
+	return nil
+}
+
+// Delete removes a published version from the server. Versions that a
+// distribution tag points at are refused, since the tag would break.
+func Delete(sshConfig config.SSHConfig, spec string) error {
+	sshClient, err := connectRegistry(sshConfig)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	request, archiveName, err := resolvePublished(sshClient, spec)
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockRegistry(sshClient)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	meta, err := loadPackageMeta(sshClient, request.Name)
+	if err != nil {
+		return err
+	}
+	if err := checkUntagged(meta, request.Name, request.Version); err != nil {
+		return err
+	}
+
+	if err := sshClient.RemoveFile(filepath.Join(sshClient.GetRemoteDir(), archiveName)); err != nil {
+		return err
+	}
+
+	meta.setYanked(request.Version, false)
+	meta.addEvent(actionDelete, request.Version, sshConfig.Username)
+	if err := savePackageMeta(sshClient, request.Name, meta); err != nil {
+		return err
+	}
+	if err := modifyIndex(sshClient, lock, func(idx *RegistryIndex) {
+		idx.remove(request.Name, request.Version)
+	}); err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted %s\n", archiveName)
+	return nil
+}
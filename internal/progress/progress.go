@@ -0,0 +1,266 @@
+// Package progress renders the progress of file transfers
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// redrawInterval limits how often bars are redrawn on a terminal
+	redrawInterval = 100 * time.Millisecond
+	// logInterval is how often a log line is written for a transfer when
+	// the output is not a terminal
+	logInterval = 5 * time.Second
+	// barWidth is the number of characters of a progress bar
+	barWidth = 25
+	// labelWidth is the number of characters of a transfer label
+	labelWidth = 28
+)
+
+// Board renders the progress of concurrent transfers. On a terminal every
+// transfer gets a bar with its size, rate and ETA that is redrawn in place;
+// otherwise a log line is written for each transfer every few seconds.
+// It is safe for concurrent use.
+type Board struct {
+	out io.Writer
+	tty bool
+	// width returns the number of terminal columns, 0 if unknown
+	width func() int
+
+	mu   sync.Mutex
+	bars []*Bar
+	// drawn is the number of bar lines currently on the terminal
+	drawn    int
+	lastDraw time.Time
+}
+
+// NewBoard returns a board writing to out, which is treated as a terminal
+// if it is a character device
+func NewBoard(out *os.File) *Board {
+	return &Board{out: out, tty: isTerminal(out), width: func() int { return terminalWidth(out) }}
+}
+
+// columnsFromEnv returns the terminal width exported in COLUMNS, or 0
+func columnsFromEnv() int {
+	columns, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || columns < 0 {
+		return 0
+	}
+	return columns
+}
+
+// isTerminal reports whether f is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
+
+// Write prints p above the bars of the board
+func (b *Board) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.clear()
+	n, err := b.out.Write(p)
+	b.draw()
+	return n, err
+}
+
+// Start adds a transfer of total bytes to the board
+func (b *Board) Start(label string, total int64) *Bar {
+	bar := &Bar{board: b, label: label, total: total, start: time.Now()}
+	bar.lastLog = bar.start
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bars = append(b.bars, bar)
+	b.redraw(true)
+	return bar
+}
+
+// remove takes a finished transfer off the board
+func (b *Board) remove(bar *Bar) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, other := range b.bars {
+		if other == bar {
+			b.bars = append(b.bars[:i], b.bars[i+1:]...)
+			break
+		}
+	}
+	b.redraw(true)
+}
+
+// redraw draws the bars again unless that happened very recently. It must
+// be called with mu held.
+func (b *Board) redraw(force bool) {
+	if !b.tty || (!force && time.Since(b.lastDraw) < redrawInterval) {
+		return
+	}
+	b.clear()
+	b.draw()
+}
+
+// clear erases the drawn bars. It must be called with mu held.
+func (b *Board) clear() {
+	if b.drawn > 0 {
+		fmt.Fprintf(b.out, "\x1b[%dA\x1b[J", b.drawn)
+		b.drawn = 0
+	}
+}
+
+// draw writes the bars below the cursor, one line each. Lines are cut to
+// the terminal width, since wrapped lines would not be cleared. It must be
+// called with mu held.
+func (b *Board) draw() {
+	if !b.tty {
+		return
+	}
+	width := 0
+	if b.width != nil {
+		width = b.width()
+	}
+	for _, bar := range b.bars {
+		line := bar.line(time.Now())
+		// Filling the last column wraps on some terminals
+		if width > 0 {
+			line = truncate(line, width-1)
+		}
+		fmt.Fprintln(b.out, line)
+	}
+	b.drawn = len(b.bars)
+	b.lastDraw = time.Now()
+}
+
+// Bar tracks a single transfer on a board
+type Bar struct {
+	board *Board
+	label string
+	start time.Time
+
+	// The fields below are guarded by the board's mutex
+	total       int64
+	done        int64
+	transferred int64
+	started     bool
+	lastLog     time.Time
+}
+
+// Update records that done of total bytes are in place. Its signature
+// matches ssh.ProgressFunc. A resumed transfer starts at the size of the
+// partial file, which does not count as transferred.
+func (bar *Bar) Update(done, total int64) {
+	b := bar.board
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if bar.started && done > bar.done {
+		bar.transferred += done - bar.done
+	}
+	bar.started = true
+	bar.done = done
+	bar.total = total
+
+	now := time.Now()
+	if b.tty {
+		b.redraw(false)
+	} else if now.Sub(bar.lastLog) >= logInterval {
+		bar.lastLog = now
+		fmt.Fprintln(b.out, bar.line(now))
+	}
+}
+
+// Finish takes the transfer off the board and returns its summary
+func (bar *Bar) Finish() Summary {
+	bar.board.remove(bar)
+
+	bar.board.mu.Lock()
+	defer bar.board.mu.Unlock()
+	return Summary{Bytes: bar.transferred, Duration: time.Since(bar.start)}
+}
+
+// line describes the state of the transfer at now
+func (bar *Bar) line(now time.Time) string {
+	rate := rate(bar.transferred, now.Sub(bar.start))
+	eta := "--"
+	if rate > 0 && bar.total > bar.done {
+		eta = formatDuration(time.Duration(float64(bar.total-bar.done) / rate * float64(time.Second)))
+	}
+
+	percent := 0
+	if bar.total > 0 {
+		percent = int(bar.done * 100 / bar.total)
+	}
+
+	if !bar.board.tty {
+		return fmt.Sprintf("%s: %s of %s (%d%%), %s/s, ETA %s",
+			bar.label, FormatSize(bar.done), FormatSize(bar.total), percent, FormatSize(int64(rate)), eta)
+	}
+
+	filled := percent * barWidth / 100
+	return fmt.Sprintf("%-*s [%s%s] %3d%% %s/%s %s/s ETA %s",
+		labelWidth, truncate(bar.label, labelWidth),
+		strings.Repeat("=", filled), strings.Repeat(" ", barWidth-filled),
+		percent, FormatSize(bar.done), FormatSize(bar.total), FormatSize(int64(rate)), eta)
+}
+
+// Summary describes a finished transfer
+type Summary struct {
+	// Bytes is the number of bytes sent over the network
+	Bytes    int64
+	Duration time.Duration
+}
+
+// String formats the summary as "<size> in <duration> (<rate>/s)"
+func (s Summary) String() string {
+	return fmt.Sprintf("%s in %s (%s/s)", FormatSize(s.Bytes), formatDuration(s.Duration), FormatSize(int64(rate(s.Bytes, s.Duration))))
+}
+
+// rate returns the bytes per second of a transfer
+func rate(bytes int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(bytes) / elapsed.Seconds()
+}
+
+// FormatSize formats a byte count for display
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// formatDuration formats a duration rounded for display
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// truncate shortens s to at most n characters
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	if n <= 3 {
+		return string(runes[:max(n, 0)])
+	}
+	return string(runes[:n-3]) + "..."
+}
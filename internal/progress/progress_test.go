@@ -0,0 +1,113 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		512:             "512 B",
+		2048:            "2.0 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+	}
+	for size, expected := range tests {
+		if got := FormatSize(size); got != expected {
+			t.Errorf("FormatSize(%d) = %s, want %s", size, got, expected)
+		}
+	}
+}
+
+func TestBarCountsTransferredBytes(t *testing.T) {
+	board := &Board{out: &bytes.Buffer{}}
+	bar := board.Start("foo-1.0.0.tar.gz", 0)
+
+	// A resumed transfer starts at the size of the partial file and a
+	// restarted one goes back to zero
+	updates := [][2]int64{{400, 1000}, {600, 1000}, {0, 1000}, {1000, 1000}}
+	for _, update := range updates {
+		bar.Update(update[0], update[1])
+	}
+
+	summary := bar.Finish()
+	if summary.Bytes != 1200 {
+		t.Errorf("Finish().Bytes = %d, want 1200", summary.Bytes)
+	}
+	if len(board.bars) != 0 {
+		t.Errorf("finished bar is still on the board")
+	}
+}
+
+func TestBoardLogLines(t *testing.T) {
+	var out bytes.Buffer
+	board := &Board{out: &out}
+	bar := board.Start("foo-1.0.0.tar.gz", 2048)
+
+	bar.Update(0, 2048)
+	bar.Update(512, 2048)
+	if out.Len() != 0 {
+		t.Errorf("log line written before the interval passed: %q", out.String())
+	}
+
+	bar.lastLog = time.Now().Add(-logInterval)
+	bar.Update(1024, 2048)
+	if line := out.String(); !strings.HasPrefix(line, "foo-1.0.0.tar.gz: 1.0 KiB of 2.0 KiB (50%)") {
+		t.Errorf("log line = %q", line)
+	}
+
+	// Without a terminal nothing is redrawn around other output
+	out.Reset()
+	board.Write([]byte("Using cached bar-1.0.0.tar.gz\n"))
+	if out.String() != "Using cached bar-1.0.0.tar.gz\n" {
+		t.Errorf("Write() output = %q", out.String())
+	}
+}
+
+func TestBoardFitsTerminalWidth(t *testing.T) {
+	var out bytes.Buffer
+	board := &Board{out: &out, tty: true, width: func() int { return 40 }}
+	bar := board.Start("a-long-package-name-1.0.0.tar.gz", 2048)
+	bar.Update(1024, 2048)
+
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		// Drop the escape sequence clearing the previous bars
+		if i := strings.LastIndex(line, "\x1b[J"); i >= 0 {
+			line = line[i+len("\x1b[J"):]
+		}
+		if len(line) > 39 {
+			t.Errorf("line of %d characters on a 40 column terminal: %q", len(line), line)
+		}
+	}
+	if board.drawn != 1 {
+		t.Errorf("drawn = %d, want 1", board.drawn)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"foo-1.0.0.tar.gz", 10, "foo-1.0..."},
+		{"päckage-1.0.0", 8, "päcka..."},
+		{"foo", 2, "fo"},
+		{"foo", 0, ""},
+	}
+
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.n); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSummaryString(t *testing.T) {
+	summary := Summary{Bytes: 2 * 1024 * 1024, Duration: 2 * time.Second}
+	if got, want := summary.String(), "2.0 MiB in 2s (1.0 MiB/s)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package progress
+
+import "os"
+
+// terminalWidth returns the number of columns of the terminal f, or 0 if
+// it cannot be determined
+func terminalWidth(f *os.File) int {
+	return columnsFromEnv()
+}
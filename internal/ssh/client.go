@@ -8,6 +8,7 @@ import (
 
 	"github.com/pkg/sftp"
 	"github.com/rasadov/package-manager/config"
+	"github.com/rasadov/package-manager/internal/utils"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -16,6 +17,10 @@ type Client struct {
 	config     config.SSHConfig
 	sshClient  *ssh.Client
 	sftpClient *sftp.Client
+	// shared is set for channels opened on another client's connection
+	shared bool
+	// limiter throttles the transfers of the client and its channels
+	limiter *rateLimiter
 }
 
 // NewClient creates a new SSH client
@@ -26,11 +31,26 @@ func NewClient(config config.SSHConfig) *Client {
 	if config.RemoteDir == "" {
 		config.RemoteDir = "/var/packages"
 	}
+	if config.Retries == 0 {
+		config.Retries = defaultRetries
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaultRetryDelay
+	}
 	return &Client{config: config}
 }
 
 // Connect establishes SSH and SFTP connections
 func (c *Client) Connect() error {
+	// Transfers of this client and its channels share one rate limit
+	if c.limiter == nil {
+		rate, err := utils.ParseRate(c.config.LimitRate)
+		if err != nil {
+			return fmt.Errorf("invalid limit_rate: %w", err)
+		}
+		c.limiter = newRateLimiter(rate)
+	}
+
 	// Load private key
 	key, err := c.loadPrivateKey()
 	if err != nil {
@@ -64,12 +84,34 @@ func (c *Client) Connect() error {
 	return nil
 }
 
+// OpenChannel opens another SFTP session over the same SSH connection, so
+// transfers can run in parallel. Closing the returned client only closes
+// that session.
+func (c *Client) OpenChannel() (*Client, error) {
+	if c.sshClient == nil {
+		return nil, fmt.Errorf("SSH client not connected")
+	}
+
+	sftpClient, err := sftp.NewClient(c.sshClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SFTP channel: %w", err)
+	}
+
+	return &Client{
+		config:     c.config,
+		sshClient:  c.sshClient,
+		sftpClient: sftpClient,
+		shared:     true,
+		limiter:    c.limiter,
+	}, nil
+}
+
 // Close closes connections
 func (c *Client) Close() error {
 	if c.sftpClient != nil {
 		c.sftpClient.Close()
 	}
-	if c.sshClient != nil {
+	if c.sshClient != nil && !c.shared {
 		c.sshClient.Close()
 	}
 	return nil
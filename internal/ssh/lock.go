@@ -0,0 +1,244 @@
+package ssh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+)
+
+const (
+	// DefaultLockTTL is how long a lock stays valid unless refreshed
+	DefaultLockTTL     = 10 * time.Minute
+	defaultLockTimeout = 60 * time.Second
+	lockRetryInterval  = 500 * time.Millisecond
+	unknownHolder      = "unknown"
+)
+
+// LockInfo describes the holder of an advisory lock
+type LockInfo struct {
+	Owner    string    `json:"owner"`
+	Host     string    `json:"host"`
+	PID      int       `json:"pid"`
+	Acquired time.Time `json:"acquired"`
+	Expires  time.Time `json:"expires"`
+}
+
+// Expired reports whether the lock is no longer valid at now
+func (i *LockInfo) Expired(now time.Time) bool {
+	return !i.Expires.IsZero() && now.After(i.Expires)
+}
+
+// sameHolder reports whether both infos describe the same acquisition
+func (i *LockInfo) sameHolder(other *LockInfo) bool {
+	return i.Owner == other.Owner && i.Host == other.Host && i.PID == other.PID && i.Acquired.Equal(other.Acquired)
+}
+
+// String describes the lock holder, e.g. "ci@build-1 (pid 42) since ..."
+func (i *LockInfo) String() string {
+	return fmt.Sprintf("%s@%s (pid %d) since %s, expires %s",
+		i.Owner, i.Host, i.PID, i.Acquired.Local().Format(time.DateTime), i.Expires.Local().Format(time.DateTime))
+}
+
+// Lock is an advisory lock held through an exclusively created remote file
+type Lock struct {
+	client *Client
+	path   string
+	info   LockInfo
+	ttl    time.Duration
+	// refreshed is when RefreshDuring last attempted a refresh
+	refreshed time.Time
+}
+
+// newLockInfo describes a lock held by this process
+func newLockInfo(ttl time.Duration) LockInfo {
+	owner := unknownHolder
+	if current, err := user.Current(); err == nil {
+		owner = current.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = unknownHolder
+	}
+
+	now := time.Now().UTC()
+	return LockInfo{
+		Owner:    owner,
+		Host:     host,
+		PID:      os.Getpid(),
+		Acquired: now,
+		Expires:  now.Add(ttl),
+	}
+}
+
+// AcquireLock creates the lock file at remotePath, waiting up to timeout
+// for the current holder to release it. Expired locks are considered stale
+// and removed. A zero ttl or timeout selects the defaults.
+func (c *Client) AcquireLock(remotePath string, ttl, timeout time.Duration) (*Lock, error) {
+	if ttl <= 0 {
+		ttl = DefaultLockTTL
+	}
+	if timeout <= 0 {
+		timeout = defaultLockTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		info := newLockInfo(ttl)
+		data, err := json.Marshal(info)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode lock: %w", err)
+		}
+
+		created, err := c.CreateExclusive(remotePath, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create lock %s: %w", remotePath, err)
+		}
+		if created {
+			return &Lock{client: c, path: remotePath, info: info, ttl: ttl, refreshed: time.Now()}, nil
+		}
+
+		holder, err := c.ReadLock(remotePath)
+		if err != nil {
+			// The holder released the lock in the meantime
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if holder.Expired(time.Now()) {
+			fmt.Printf("Removing stale lock held by %s\n", holder)
+			if err := c.removeLockIfUnchanged(remotePath, holder); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is locked by %s (run pm registry unlock if the holder is gone)", remotePath, holder)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// ReadLock returns the holder of the lock at remotePath. The returned error
+// satisfies os.IsNotExist if nobody holds the lock.
+func (c *Client) ReadLock(remotePath string) (*LockInfo, error) {
+	if c.sftpClient == nil {
+		return nil, fmt.Errorf("SFTP client not connected")
+	}
+
+	remoteFile, err := c.sftpClient.Open(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	defer remoteFile.Close()
+
+	var info LockInfo
+	if err := json.NewDecoder(remoteFile).Decode(&info); err != nil {
+		// An unreadable lock, e.g. from an interrupted writer, counts as
+		// expired once it is older than the default lifetime
+		stat, statErr := remoteFile.Stat()
+		if statErr != nil {
+			return nil, fmt.Errorf("failed to read lock %s: %w", remotePath, err)
+		}
+		return &LockInfo{
+			Owner:    unknownHolder,
+			Host:     unknownHolder,
+			Acquired: stat.ModTime(),
+			Expires:  stat.ModTime().Add(DefaultLockTTL),
+		}, nil
+	}
+	return &info, nil
+}
+
+// removeLockIfUnchanged removes a stale lock unless another process
+// replaced it since it was read
+func (c *Client) removeLockIfUnchanged(remotePath string, stale *LockInfo) error {
+	current, err := c.ReadLock(remotePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if !current.sameHolder(stale) {
+		return nil
+	}
+	return c.RemoveFile(remotePath)
+}
+
+// ForceUnlock removes the lock at remotePath regardless of its holder
+func (c *Client) ForceUnlock(remotePath string) error {
+	return c.RemoveFile(remotePath)
+}
+
+// Refresh extends the lock by ttl from now, for holders of long operations.
+// It fails if the lock was removed or taken over after expiring.
+func (l *Lock) Refresh(ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = DefaultLockTTL
+	}
+
+	current, err := l.client.ReadLock(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("lock %s was removed", l.path)
+		}
+		return err
+	}
+	if !current.sameHolder(&l.info) {
+		return fmt.Errorf("lock %s was taken over by %s", l.path, current)
+	}
+
+	info := l.info
+	info.Expires = time.Now().UTC().Add(ttl)
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to encode lock: %w", err)
+	}
+	// Rewritten in place: replacing the file by rename could let another
+	// process create the lock in between on servers without posix-rename
+	if err := l.client.OverwriteFile(l.path, data); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("lock %s was removed", l.path)
+		}
+		return err
+	}
+
+	l.info = info
+	return nil
+}
+
+// RefreshDuring wraps the progress callback of a transfer so the lock is
+// refreshed every third of its lifetime while the transfer runs. Failed
+// refreshes are retried on the next interval; callers refresh once more
+// after the transfer to learn whether they still hold the lock.
+func (l *Lock) RefreshDuring(progress ProgressFunc) ProgressFunc {
+	return func(done, total int64) {
+		if time.Since(l.refreshed) >= l.ttl/3 {
+			l.refreshed = time.Now()
+			l.Refresh(l.ttl)
+		}
+		if progress != nil {
+			progress(done, total)
+		}
+	}
+}
+
+// Release removes the lock unless it was taken over after expiring
+func (l *Lock) Release() error {
+	current, err := l.client.ReadLock(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if !current.sameHolder(&l.info) {
+		return fmt.Errorf("lock %s was taken over by %s", l.path, current)
+	}
+	return l.client.RemoveFile(l.path)
+}
@@ -0,0 +1,160 @@
+package ssh
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// newTestClient returns a client connected to an in-memory SFTP server
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	server := sftp.NewRequestServer(serverConn, sftp.InMemHandler())
+	go server.Serve()
+
+	sftpClient, err := sftp.NewClientPipe(clientConn, clientConn)
+	if err != nil {
+		t.Fatalf("failed to start SFTP client: %v", err)
+	}
+	t.Cleanup(func() {
+		sftpClient.Close()
+		server.Close()
+	})
+
+	return &Client{sftpClient: sftpClient}
+}
+
+func TestLockInfoExpired(t *testing.T) {
+	info := newLockInfo(time.Minute)
+	if info.Expired(time.Now()) {
+		t.Errorf("new lock is expired")
+	}
+	if !info.Expired(time.Now().Add(2 * time.Minute)) {
+		t.Errorf("lock is not expired after its ttl")
+	}
+	if info.Owner == "" || info.Host == "" || info.PID == 0 {
+		t.Errorf("newLockInfo() = %+v, want owner, host and pid", info)
+	}
+}
+
+func TestLockInfoSameHolder(t *testing.T) {
+	info := newLockInfo(time.Minute)
+
+	// Refreshing only moves the expiry
+	refreshed := info
+	refreshed.Expires = refreshed.Expires.Add(time.Hour)
+	if !info.sameHolder(&refreshed) {
+		t.Errorf("refreshed lock has a different holder")
+	}
+
+	other := info
+	other.PID++
+	if info.sameHolder(&other) {
+		t.Errorf("lock of another process has the same holder")
+	}
+}
+
+func TestLockRefresh(t *testing.T) {
+	const lockPath = "/.lock"
+
+	tests := []struct {
+		name    string
+		change  func(c *Client) error
+		wantErr string
+	}{
+		{"held", func(c *Client) error { return nil }, ""},
+		{"removed", func(c *Client) error { return c.RemoveFile(lockPath) }, "was removed"},
+		{"taken over", func(c *Client) error {
+			other := newLockInfo(time.Minute)
+			other.PID++
+			data, err := json.Marshal(other)
+			if err != nil {
+				return err
+			}
+			return c.WriteFile(lockPath, data)
+		}, "was taken over"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t)
+			lock, err := client.AcquireLock(lockPath, time.Minute, time.Second)
+			if err != nil {
+				t.Fatalf("AcquireLock() error = %v", err)
+			}
+			if err := tt.change(client); err != nil {
+				t.Fatalf("failed to change lock: %v", err)
+			}
+			before, _ := client.ReadLock(lockPath)
+
+			err = lock.Refresh(time.Hour)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Refresh() error = %v, want %q", err, tt.wantErr)
+				}
+				// The lock of another holder is left alone
+				after, readErr := client.ReadLock(lockPath)
+				if before == nil {
+					if !os.IsNotExist(readErr) {
+						t.Errorf("Refresh() recreated a removed lock")
+					}
+				} else if readErr != nil || !after.Expires.Equal(before.Expires) {
+					t.Errorf("Refresh() rewrote the lock of another holder")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Refresh() error = %v", err)
+			}
+
+			after, err := client.ReadLock(lockPath)
+			if err != nil {
+				t.Fatalf("ReadLock() error = %v", err)
+			}
+			if !after.Expires.After(before.Expires) {
+				t.Errorf("Refresh() kept expiry %s", after.Expires)
+			}
+			if err := lock.Release(); err != nil {
+				t.Errorf("Release() after Refresh() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLockRefreshDuring(t *testing.T) {
+	const lockPath = "/.lock"
+
+	client := newTestClient(t)
+	lock, err := client.AcquireLock(lockPath, time.Minute, time.Second)
+	if err != nil {
+		t.Fatalf("AcquireLock() error = %v", err)
+	}
+	acquired, _ := client.ReadLock(lockPath)
+
+	var calls int
+	progress := lock.RefreshDuring(func(done, total int64) { calls++ })
+
+	// Early progress does not touch the lock
+	progress(1, 10)
+	if current, _ := client.ReadLock(lockPath); !current.Expires.Equal(acquired.Expires) {
+		t.Errorf("lock refreshed before a third of its lifetime")
+	}
+
+	// Progress after a third of the lifetime extends it
+	lock.refreshed = time.Now().Add(-time.Minute / 3)
+	progress(2, 10)
+	if current, _ := client.ReadLock(lockPath); !current.Expires.After(acquired.Expires) {
+		t.Errorf("lock not refreshed after a third of its lifetime")
+	}
+
+	if calls != 2 {
+		t.Errorf("progress called %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,60 @@
+package ssh
+
+import (
+	"sync"
+	"time"
+)
+
+// minBurst is the smallest number of bytes a rate limiter lets through at
+// once, about one SFTP packet
+const minBurst = 32 * 1024
+
+// rateLimiter is a token bucket shared by all transfers of a client and
+// the channels opened on it, so parallel transfers stay within one limit
+// combined. Callers may overdraw the bucket and then wait until the debt is
+// paid off, which queues them in order.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter for bytesPerSecond, or nil for no limit
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	burst := max(float64(bytesPerSecond)/10, minBurst)
+	return &rateLimiter{
+		rate:   float64(bytesPerSecond),
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until n more bytes may be transferred. A nil limiter never
+// blocks.
+func (l *rateLimiter) wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	time.Sleep(l.reserve(n, time.Now()))
+}
+
+// reserve takes n bytes from the bucket at now and returns how long the
+// caller has to wait for them
+func (l *rateLimiter) reserve(n int, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
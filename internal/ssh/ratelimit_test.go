@@ -0,0 +1,42 @@
+package ssh
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	const rate = 100 * 1024
+	limiter := newRateLimiter(rate)
+	start := limiter.last
+
+	// The burst goes through at once
+	if wait := limiter.reserve(minBurst, start); wait != 0 {
+		t.Errorf("reserve(burst) = %v, want 0", wait)
+	}
+
+	// Parallel transfers share the bucket and queue behind each other
+	if wait := limiter.reserve(rate, start); wait != time.Second {
+		t.Errorf("reserve(rate) = %v, want 1s", wait)
+	}
+	if wait := limiter.reserve(rate/2, start); wait != 1500*time.Millisecond {
+		t.Errorf("reserve(rate/2) = %v, want 1.5s", wait)
+	}
+
+	// Idle time refills the bucket up to the burst only
+	if wait := limiter.reserve(minBurst, start.Add(time.Minute)); wait != 0 {
+		t.Errorf("reserve() after idle = %v, want 0", wait)
+	}
+	if wait := limiter.reserve(rate, start.Add(time.Minute)); wait != time.Second {
+		t.Errorf("reserve() after burst = %v, want 1s", wait)
+	}
+}
+
+func TestNewRateLimiterUnlimited(t *testing.T) {
+	limiter := newRateLimiter(0)
+	if limiter != nil {
+		t.Fatalf("newRateLimiter(0) = %+v, want nil", limiter)
+	}
+	// A nil limiter never blocks
+	limiter.wait(1 << 30)
+}
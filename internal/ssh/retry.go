@@ -0,0 +1,121 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+const (
+	// defaultRetries is how often an interrupted transfer is resumed
+	defaultRetries = 3
+	// defaultRetryDelay is the number of seconds before the first retry
+	defaultRetryDelay = 2
+	// keepaliveTimeout is how long a live connection takes to answer
+	keepaliveTimeout = 10 * time.Second
+)
+
+// ErrChecksumMismatch is returned when a transferred file does not match
+// its expected SHA-256
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// retry runs a transfer and, while it fails with a network error,
+// reconnects and runs it again as configured by the retries and
+// retry_delay settings. The delay doubles after every attempt. A checksum
+// mismatch drops the partial data, so the transfer is started over once
+// in case that data came from an earlier version of the file.
+func (c *Client) retry(transfer func() error) error {
+	delay := time.Duration(c.config.RetryDelay) * time.Second
+	attempts := 0
+	restarted := false
+
+	err := transfer()
+	for err != nil {
+		switch {
+		case errors.Is(err, ErrChecksumMismatch) && !restarted:
+			restarted = true
+		case retryable(err) && attempts < c.config.Retries:
+			attempts++
+			time.Sleep(delay)
+			delay *= 2
+
+			// Dial errors are retried, a missing key is not
+			if reconnectErr := c.reconnect(); reconnectErr != nil {
+				err = fmt.Errorf("failed to reconnect: %w", reconnectErr)
+				continue
+			}
+		default:
+			if attempts > 0 && retryable(err) {
+				return fmt.Errorf("%w (gave up after %d retries)", err, attempts)
+			}
+			return err
+		}
+		err = transfer()
+	}
+	return nil
+}
+
+// retryable reports whether err is a dropped or refused connection, which
+// may succeed after reconnecting
+func retryable(err error) bool {
+	// Not net.Error, which every syscall.Errno satisfies
+	var opErr *net.OpError
+	return errors.Is(err, sftp.ErrSSHFxConnectionLost) ||
+		errors.Is(err, sftp.ErrSSHFxNoConnection) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.As(err, &opErr)
+}
+
+// reconnect replaces the SFTP session of the client. While the SSH
+// connection still answers, only the session is reopened, since channels
+// of parallel transfers may run on the same connection. Otherwise the
+// client dials again, and a channel opened on another client's connection
+// gets a connection of its own.
+func (c *Client) reconnect() error {
+	if c.sftpClient != nil {
+		c.sftpClient.Close()
+		c.sftpClient = nil
+	}
+
+	if c.sshClient != nil && c.connectionAlive() {
+		sftpClient, err := sftp.NewClient(c.sshClient)
+		if err == nil {
+			c.sftpClient = sftpClient
+			return nil
+		}
+	}
+
+	if c.sshClient != nil && !c.shared {
+		c.sshClient.Close()
+	}
+	c.sshClient, c.shared = nil, false
+
+	return c.Connect()
+}
+
+// connectionAlive reports whether the SSH connection answers a keepalive
+// within keepaliveTimeout. A dropped VPN can leave the connection hanging
+// rather than closed.
+func (c *Client) connectionAlive() bool {
+	answered := make(chan bool, 1)
+	go func() {
+		_, _, err := c.sshClient.SendRequest("keepalive@openssh.com", true, nil)
+		answered <- err == nil
+	}()
+
+	select {
+	case alive := <-answered:
+		return alive
+	case <-time.After(keepaliveTimeout):
+		return false
+	}
+}
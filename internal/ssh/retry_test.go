@@ -0,0 +1,60 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/rasadov/package-manager/config"
+)
+
+func TestRetry(t *testing.T) {
+	dropped := fmt.Errorf("failed to download file: %w", sftp.ErrSSHFxConnectionLost)
+	mismatch := fmt.Errorf("download is corrupted: %w", ErrChecksumMismatch)
+
+	tests := []struct {
+		name      string
+		retries   int
+		errs      []error
+		wantCalls int
+		wantErr   string
+	}{
+		{"succeeds", 3, []error{nil}, 1, ""},
+		{"missing file is not retried", 3, []error{fmt.Errorf("open: %w", os.ErrNotExist)}, 1, "file does not exist"},
+		{"full disk is not retried", 3, []error{fmt.Errorf("write: %w", syscall.ENOSPC)}, 1, "no space left"},
+		{"checksum mismatch starts over once", 3, []error{mismatch, nil}, 2, ""},
+		{"repeated checksum mismatch fails", 3, []error{mismatch, mismatch, nil}, 2, "checksum mismatch"},
+		{"retries disabled", -1, []error{dropped}, 1, "connection lost"},
+		{"missing key stops reconnecting", 2, []error{dropped, nil}, 1, "failed to reconnect"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{config: config.SSHConfig{
+				KeyPath: "/nonexistent/id_rsa",
+				Retries: tt.retries,
+			}}
+
+			calls := 0
+			err := c.retry(func() error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+
+			if calls != tt.wantCalls {
+				t.Errorf("retry() ran the transfer %d times, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("retry() error = %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("retry() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,74 +1,349 @@
 package ssh
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+
+	"github.com/rasadov/package-manager/internal/utils"
 )
 
-// UploadFile uploads a local file to the remote server
-func (c *Client) UploadFile(localPath, remotePath string) error {
+// partialSuffix marks remote files that are still being written
+const partialSuffix = ".partial"
+
+// ProgressFunc is called during a transfer with the number of bytes of the
+// file that are in place and its size
+type ProgressFunc func(done, total int64)
+
+// transferReader applies the rate limit to the bytes read through it and
+// reports their progress
+type transferReader struct {
+	r        io.Reader
+	done     int64
+	total    int64
+	limiter  *rateLimiter
+	progress ProgressFunc
+}
+
+func (t *transferReader) Read(b []byte) (int, error) {
+	n, err := t.r.Read(b)
+	t.limiter.wait(n)
+	t.done += int64(n)
+	if t.progress != nil {
+		t.progress(t.done, t.total)
+	}
+	return n, err
+}
+
+// Len returns the number of bytes left to read, which lets SFTP uploads
+// send several packets at once
+func (t *transferReader) Len() int {
+	return int(t.total - t.done)
+}
+
+// transferWriter applies the rate limit to the bytes written through it
+// and reports their progress. Downloads are metered on the writing side,
+// so SFTP can still read several packets at once.
+type transferWriter struct {
+	w        io.Writer
+	done     int64
+	total    int64
+	limiter  *rateLimiter
+	progress ProgressFunc
+}
+
+func (t *transferWriter) Write(b []byte) (int, error) {
+	t.limiter.wait(len(b))
+	n, err := t.w.Write(b)
+	t.done += int64(n)
+	if t.progress != nil {
+		t.progress(t.done, t.total)
+	}
+	return n, err
+}
+
+// UploadFile uploads a local file to the remote server. The file is written
+// to a temporary name, verified by size and SHA-256 and then renamed into
+// place, so readers never see a partially uploaded file. An interrupted
+// upload resumes from the end of the temporary file, after reconnecting
+// within the same call or on the next upload of the same path. A non-nil
+// progress is called as data is sent.
+func (c *Client) UploadFile(localPath, remotePath string, progress ProgressFunc) error {
 	if c.sftpClient == nil {
 		return fmt.Errorf("SFTP client not connected")
 	}
 
-	// Open local file
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat local file %s: %w", localPath, err)
+	}
+	checksum, err := utils.FileChecksum(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to checksum local file %s: %w", localPath, err)
+	}
+
+	partialPath := remotePath + partialSuffix
+	if err := c.retry(func() error {
+		if err := c.resumeUpload(localPath, partialPath, info.Size(), progress); err != nil {
+			return err
+		}
+		// A temporary file left by a different upload does not match, so
+		// it is dropped and the next attempt starts over
+		if err := c.verifyUpload(partialPath, info.Size(), checksum); err != nil {
+			c.sftpClient.Remove(partialPath)
+			return err
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := c.Rename(partialPath, remotePath); err != nil {
+		c.sftpClient.Remove(partialPath)
+		return err
+	}
+
+	return nil
+}
+
+// resumeUpload writes the part of a local file that is missing from the
+// temporary remote file
+func (c *Client) resumeUpload(localPath, partialPath string, size int64, progress ProgressFunc) error {
 	localFile, err := os.Open(localPath)
 	if err != nil {
 		return fmt.Errorf("failed to open local file %s: %w", localPath, err)
 	}
 	defer localFile.Close()
 
-	// Create remote file
-	remoteFile, err := c.sftpClient.Create(remotePath)
+	remoteFile, err := c.sftpClient.OpenFile(partialPath, os.O_WRONLY|os.O_CREATE)
 	if err != nil {
-		return fmt.Errorf("failed to create remote file %s: %w", remotePath, err)
+		return fmt.Errorf("failed to create remote file %s: %w", partialPath, err)
 	}
 	defer remoteFile.Close()
 
-	// Copy file content
-	_, err = io.Copy(remoteFile, localFile)
+	info, err := remoteFile.Stat()
 	if err != nil {
+		return fmt.Errorf("failed to stat remote file %s: %w", partialPath, err)
+	}
+	offset := info.Size()
+	if offset > size {
+		if err := remoteFile.Truncate(0); err != nil {
+			return fmt.Errorf("failed to truncate remote file %s: %w", partialPath, err)
+		}
+		offset = 0
+	}
+
+	if _, err := localFile.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek local file %s: %w", localPath, err)
+	}
+	if _, err := remoteFile.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek remote file %s: %w", partialPath, err)
+	}
+	if progress != nil {
+		progress(offset, size)
+	}
+	src := &transferReader{r: localFile, done: offset, total: size, limiter: c.limiter, progress: progress}
+	if _, err := io.Copy(remoteFile, src); err != nil {
 		return fmt.Errorf("failed to upload file: %w", err)
 	}
+	if err := remoteFile.Close(); err != nil {
+		return fmt.Errorf("failed to finish upload of %s: %w", partialPath, err)
+	}
 
 	return nil
 }
 
-// DownloadFile downloads a file from the remote server to local path
-func (c *Client) DownloadFile(remotePath, localPath string) error {
+// uploadPartial streams a local file to a temporary remote path
+func (c *Client) uploadPartial(localFile io.Reader, partialPath string) error {
+	remoteFile, err := c.sftpClient.Create(partialPath)
+	if err != nil {
+		return fmt.Errorf("failed to create remote file %s: %w", partialPath, err)
+	}
+
+	if _, err := io.Copy(remoteFile, localFile); err != nil {
+		remoteFile.Close()
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	if err := remoteFile.Close(); err != nil {
+		return fmt.Errorf("failed to finish upload of %s: %w", partialPath, err)
+	}
+
+	return nil
+}
+
+// verifyUpload reads an uploaded file back and compares its size and
+// SHA-256 with what was sent
+func (c *Client) verifyUpload(remotePath string, size int64, checksum string) error {
+	info, err := c.sftpClient.Stat(remotePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat uploaded file %s: %w", remotePath, err)
+	}
+	if info.Size() != size {
+		return fmt.Errorf("upload of %s is incomplete: %d of %d bytes written", remotePath, info.Size(), size)
+	}
+
+	uploaded, err := c.FileChecksum(remotePath)
+	if err != nil {
+		return err
+	}
+	if uploaded != checksum {
+		return fmt.Errorf("upload of %s is corrupted: %w: got %s, expected %s", remotePath, ErrChecksumMismatch, uploaded, checksum)
+	}
+
+	return nil
+}
+
+// FileChecksum reads a remote file and returns its SHA-256 as a hex string
+func (c *Client) FileChecksum(remotePath string) (string, error) {
 	if c.sftpClient == nil {
-		return fmt.Errorf("SFTP client not connected")
+		return "", fmt.Errorf("SFTP client not connected")
 	}
 
-	// Open remote file
 	remoteFile, err := c.sftpClient.Open(remotePath)
 	if err != nil {
-		return fmt.Errorf("failed to open remote file %s: %w", remotePath, err)
+		return "", fmt.Errorf("failed to open remote file %s: %w", remotePath, err)
 	}
 	defer remoteFile.Close()
 
+	hash := sha256.New()
+	if _, err := io.Copy(&transferWriter{w: hash, limiter: c.limiter}, remoteFile); err != nil {
+		return "", fmt.Errorf("failed to read remote file %s: %w", remotePath, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// DownloadFile downloads a file from the remote server to local path. The
+// data is written to a temporary file next to localPath first, and an
+// interrupted download resumes from its end after reconnecting. A non-empty
+// checksum is the SHA-256 the file must match before it is moved to
+// localPath; only then is a temporary file left by an earlier call resumed,
+// since it is verified in the end. A non-nil progress is called as data is
+// received.
+func (c *Client) DownloadFile(remotePath, localPath, checksum string, progress ProgressFunc) error {
+	if c.sftpClient == nil {
+		return fmt.Errorf("SFTP client not connected")
+	}
+
 	// Ensure local directory exists
 	localDir := filepath.Dir(localPath)
 	if err := os.MkdirAll(localDir, 0755); err != nil {
 		return fmt.Errorf("failed to create local directory %s: %w", localDir, err)
 	}
 
-	// Create local file
-	localFile, err := os.Create(localPath)
+	// Without a checksum a partial file cannot be verified, so one left by
+	// an earlier call is dropped and data of an earlier attempt is only
+	// resumed while the remote file is unchanged
+	partialPath := localPath + partialSuffix
+	var started *os.FileInfo
+	if checksum == "" {
+		if err := os.Remove(partialPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove partial download %s: %w", partialPath, err)
+		}
+		started = new(os.FileInfo)
+	}
+
+	if err := c.retry(func() error {
+		if err := c.resumeDownload(remotePath, partialPath, started, progress); err != nil {
+			return err
+		}
+		if checksum == "" {
+			return nil
+		}
+
+		// A partial file left by a different download does not match, so
+		// it is dropped and the next attempt starts over
+		downloaded, err := utils.FileChecksum(partialPath)
+		if err != nil {
+			return fmt.Errorf("failed to checksum downloaded file %s: %w", partialPath, err)
+		}
+		if downloaded != checksum {
+			os.Remove(partialPath)
+			return fmt.Errorf("download of %s is corrupted: %w: got %s, expected %s", remotePath, ErrChecksumMismatch, downloaded, checksum)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := os.Rename(partialPath, localPath); err != nil {
+		return fmt.Errorf("failed to move downloaded file to %s: %w", localPath, err)
+	}
+
+	return nil
+}
+
+// resumeDownload appends the part of a remote file that is missing from
+// the local partial file. A non-nil started records the remote file as it
+// was when the partial file was begun; the partial file is dropped if the
+// remote file changed since.
+func (c *Client) resumeDownload(remotePath, partialPath string, started *os.FileInfo, progress ProgressFunc) error {
+	// Open remote file
+	remoteFile, err := c.sftpClient.Open(remotePath)
+	if err != nil {
+		return fmt.Errorf("failed to open remote file %s: %w", remotePath, err)
+	}
+	defer remoteFile.Close()
+
+	info, err := remoteFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat remote file %s: %w", remotePath, err)
+	}
+
+	localFile, err := os.OpenFile(partialPath, os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to create local file %s: %w", localPath, err)
+		return fmt.Errorf("failed to create local file %s: %w", partialPath, err)
 	}
 	defer localFile.Close()
 
+	offset, err := localFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed to seek local file %s: %w", partialPath, err)
+	}
+	stale := offset > info.Size()
+	if started != nil {
+		if *started != nil && !sameVersion(*started, info) {
+			stale = true
+		}
+		*started = info
+	}
+	if stale {
+		if err := localFile.Truncate(0); err != nil {
+			return fmt.Errorf("failed to truncate local file %s: %w", partialPath, err)
+		}
+		offset = 0
+	}
+
+	if _, err := localFile.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek local file %s: %w", partialPath, err)
+	}
+	if _, err := remoteFile.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek remote file %s: %w", remotePath, err)
+	}
+
 	// Copy file content
-	_, err = io.Copy(localFile, remoteFile)
+	if progress != nil {
+		progress(offset, info.Size())
+	}
+	dst := &transferWriter{w: localFile, done: offset, total: info.Size(), limiter: c.limiter, progress: progress}
+	written, err := io.Copy(dst, remoteFile)
 	if err != nil {
 		return fmt.Errorf("failed to download file: %w", err)
 	}
+	if offset+written != info.Size() {
+		return fmt.Errorf("download of %s is incomplete: %d of %d bytes received", remotePath, offset+written, info.Size())
+	}
 
-	return nil
+	return localFile.Close()
+}
+
+// sameVersion reports whether two stats of a remote file describe the
+// same content, judged by size and modification time
+func sameVersion(a, b os.FileInfo) bool {
+	return a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
 }
 
 // ListFiles lists files in a remote directory
@@ -139,3 +414,171 @@ func (c *Client) GetFileSize(remotePath string) (int64, error) {
 
 	return info.Size(), nil
 }
+
+// ListFileInfos lists the files in a remote directory with their size and
+// modification time
+func (c *Client) ListFileInfos(remotePath string) ([]os.FileInfo, error) {
+	if c.sftpClient == nil {
+		return nil, fmt.Errorf("SFTP client not connected")
+	}
+
+	files, err := c.sftpClient.ReadDir(remotePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote directory %s: %w", remotePath, err)
+	}
+
+	var infos []os.FileInfo
+	for _, file := range files {
+		if !file.IsDir() {
+			infos = append(infos, file)
+		}
+	}
+
+	return infos, nil
+}
+
+// OpenFile opens a remote file for reading
+func (c *Client) OpenFile(remotePath string) (io.ReadCloser, error) {
+	if c.sftpClient == nil {
+		return nil, fmt.Errorf("SFTP client not connected")
+	}
+
+	remoteFile, err := c.sftpClient.Open(remotePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open remote file %s: %w", remotePath, err)
+	}
+
+	return remoteFile, nil
+}
+
+// ReadFile returns the content of a remote file. The returned error wraps
+// os.ErrNotExist if the file does not exist.
+func (c *Client) ReadFile(remotePath string) ([]byte, error) {
+	if c.sftpClient == nil {
+		return nil, fmt.Errorf("SFTP client not connected")
+	}
+
+	remoteFile, err := c.sftpClient.Open(remotePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open remote file %s: %w", remotePath, err)
+	}
+	defer remoteFile.Close()
+
+	data, err := io.ReadAll(remoteFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote file %s: %w", remotePath, err)
+	}
+
+	return data, nil
+}
+
+// WriteFile replaces the content of a remote file atomically
+func (c *Client) WriteFile(remotePath string, data []byte) error {
+	if c.sftpClient == nil {
+		return fmt.Errorf("SFTP client not connected")
+	}
+
+	partialPath := remotePath + partialSuffix
+	if err := c.uploadPartial(bytes.NewReader(data), partialPath); err != nil {
+		c.sftpClient.Remove(partialPath)
+		return err
+	}
+	if err := c.Rename(partialPath, remotePath); err != nil {
+		c.sftpClient.Remove(partialPath)
+		return err
+	}
+
+	return nil
+}
+
+// OverwriteFile replaces the content of an existing remote file in place.
+// Unlike WriteFile, the file exists throughout; the content is written
+// before truncating so readers never see it empty. The returned error wraps
+// os.ErrNotExist if the file does not exist.
+func (c *Client) OverwriteFile(remotePath string, data []byte) error {
+	if c.sftpClient == nil {
+		return fmt.Errorf("SFTP client not connected")
+	}
+
+	remoteFile, err := c.sftpClient.OpenFile(remotePath, os.O_WRONLY)
+	if err != nil {
+		return fmt.Errorf("failed to open remote file %s: %w", remotePath, err)
+	}
+	defer remoteFile.Close()
+
+	if _, err := remoteFile.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("failed to write remote file %s: %w", remotePath, err)
+	}
+	if err := remoteFile.Truncate(int64(len(data))); err != nil {
+		return fmt.Errorf("failed to truncate remote file %s: %w", remotePath, err)
+	}
+
+	return nil
+}
+
+// RemoveFile deletes a remote file
+func (c *Client) RemoveFile(remotePath string) error {
+	if c.sftpClient == nil {
+		return fmt.Errorf("SFTP client not connected")
+	}
+
+	if err := c.sftpClient.Remove(remotePath); err != nil {
+		return fmt.Errorf("failed to remove remote file %s: %w", remotePath, err)
+	}
+
+	return nil
+}
+
+// CreateExclusive creates a remote file with the given content unless it
+// already exists, and reports whether it was created
+func (c *Client) CreateExclusive(remotePath string, data []byte) (bool, error) {
+	if c.sftpClient == nil {
+		return false, fmt.Errorf("SFTP client not connected")
+	}
+
+	remoteFile, err := c.sftpClient.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		// Servers report an existing file with different status codes
+		if _, statErr := c.sftpClient.Stat(remotePath); statErr == nil {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to create remote file %s: %w", remotePath, err)
+	}
+	defer remoteFile.Close()
+
+	if _, err := remoteFile.Write(data); err != nil {
+		return true, fmt.Errorf("failed to write remote file %s: %w", remotePath, err)
+	}
+
+	return true, nil
+}
+
+// posixRenameExtension is the OpenSSH extension for renames that replace
+// the target atomically
+const posixRenameExtension = "posix-rename@openssh.com"
+
+// Rename moves a remote file, replacing newPath if it exists. The
+// replacement is atomic on servers supporting the posix-rename extension;
+// elsewhere newPath is removed first.
+func (c *Client) Rename(oldPath, newPath string) error {
+	if c.sftpClient == nil {
+		return fmt.Errorf("SFTP client not connected")
+	}
+
+	if _, ok := c.sftpClient.HasExtension(posixRenameExtension); ok {
+		if err := c.sftpClient.PosixRename(oldPath, newPath); err != nil {
+			return fmt.Errorf("failed to rename remote file %s to %s: %w", oldPath, newPath, err)
+		}
+		return nil
+	}
+
+	// Plain SFTP renames fail if the target exists
+	if err := c.sftpClient.Remove(newPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace remote file %s: %w", newPath, err)
+	}
+	if err := c.sftpClient.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("failed to rename remote file %s to %s: %w", oldPath, newPath, err)
+	}
+
+	return nil
+}
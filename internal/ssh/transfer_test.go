@@ -0,0 +1,231 @@
+package ssh
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rasadov/package-manager/internal/utils"
+)
+
+const testContent = "0123456789abcdefghij"
+
+// writeRemote creates a remote file with the given content
+func writeRemote(t *testing.T, c *Client, remotePath, content string) os.FileInfo {
+	t.Helper()
+	if err := c.WriteFile(remotePath, []byte(content)); err != nil {
+		t.Fatalf("failed to write remote file: %v", err)
+	}
+	info, err := c.sftpClient.Stat(remotePath)
+	if err != nil {
+		t.Fatalf("failed to stat remote file: %v", err)
+	}
+	return info
+}
+
+// readRemote returns the content of a remote file
+func readRemote(t *testing.T, c *Client, remotePath string) string {
+	t.Helper()
+	data, err := c.ReadFile(remotePath)
+	if err != nil {
+		t.Fatalf("failed to read remote file: %v", err)
+	}
+	return string(data)
+}
+
+func TestResumeDownload(t *testing.T) {
+	tests := []struct {
+		name string
+		// partial is the content of the local partial file, if any
+		partial string
+		// changed records the partial file as begun on another version
+		changed    bool
+		wantOffset int64
+	}{
+		{"no partial file", "", false, 0},
+		{"resumes from the offset", testContent[:5], false, 5},
+		{"truncates an oversized partial", testContent + "extra", false, 0},
+		{"drops a partial of another version", "XXXXX", true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t)
+			info := writeRemote(t, client, "/pkg.tar.gz", testContent)
+
+			partialPath := filepath.Join(t.TempDir(), "pkg.tar.gz.partial")
+			if tt.partial != "" {
+				if err := os.WriteFile(partialPath, []byte(tt.partial), 0644); err != nil {
+					t.Fatalf("failed to write partial file: %v", err)
+				}
+			}
+			started := new(os.FileInfo)
+			if tt.changed {
+				*started = writeRemote(t, client, "/other.tar.gz", testContent+"more")
+			}
+
+			offset := int64(-1)
+			err := client.resumeDownload("/pkg.tar.gz", partialPath, started, func(done, total int64) {
+				if offset < 0 {
+					offset = done
+				}
+			})
+			if err != nil {
+				t.Fatalf("resumeDownload() error = %v", err)
+			}
+
+			if offset != tt.wantOffset {
+				t.Errorf("resumed from %d, want %d", offset, tt.wantOffset)
+			}
+			data, _ := os.ReadFile(partialPath)
+			if string(data) != testContent {
+				t.Errorf("downloaded %q, want %q", data, testContent)
+			}
+			if *started == nil || !sameVersion(*started, info) {
+				t.Errorf("started not recorded as the downloaded version")
+			}
+		})
+	}
+}
+
+func TestDownloadFilePartials(t *testing.T) {
+	tests := []struct {
+		name     string
+		partial  string
+		checksum bool
+	}{
+		{"mismatched partial starts over", "XXXXX", true},
+		{"partial without checksum is dropped", "XXXXX", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t)
+			client.config.Retries = 1
+			writeRemote(t, client, "/pkg.tar.gz", testContent)
+
+			localPath := filepath.Join(t.TempDir(), "pkg.tar.gz")
+			if err := os.WriteFile(localPath+partialSuffix, []byte(tt.partial), 0644); err != nil {
+				t.Fatalf("failed to write partial file: %v", err)
+			}
+			checksum := ""
+			if tt.checksum {
+				source := filepath.Join(t.TempDir(), "source")
+				os.WriteFile(source, []byte(testContent), 0644)
+				checksum, _ = utils.FileChecksum(source)
+			}
+
+			if err := client.DownloadFile("/pkg.tar.gz", localPath, checksum, nil); err != nil {
+				t.Fatalf("DownloadFile() error = %v", err)
+			}
+			data, _ := os.ReadFile(localPath)
+			if string(data) != testContent {
+				t.Errorf("downloaded %q, want %q", data, testContent)
+			}
+			if _, err := os.Stat(localPath + partialSuffix); !os.IsNotExist(err) {
+				t.Errorf("partial file left behind")
+			}
+		})
+	}
+}
+
+func TestResumeUpload(t *testing.T) {
+	tests := []struct {
+		name       string
+		partial    string
+		wantOffset int64
+	}{
+		{"no partial file", "", 0},
+		{"resumes from the offset", testContent[:5], 5},
+		{"truncates an oversized partial", testContent + "extra", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t)
+			localPath := filepath.Join(t.TempDir(), "pkg.tar.gz")
+			if err := os.WriteFile(localPath, []byte(testContent), 0644); err != nil {
+				t.Fatalf("failed to write local file: %v", err)
+			}
+			if tt.partial != "" {
+				writeRemote(t, client, "/pkg.tar.gz.partial", tt.partial)
+			}
+
+			offset := int64(-1)
+			err := client.resumeUpload(localPath, "/pkg.tar.gz.partial", int64(len(testContent)), func(done, total int64) {
+				if offset < 0 {
+					offset = done
+				}
+			})
+			if err != nil {
+				t.Fatalf("resumeUpload() error = %v", err)
+			}
+
+			if offset != tt.wantOffset {
+				t.Errorf("resumed from %d, want %d", offset, tt.wantOffset)
+			}
+			if got := readRemote(t, client, "/pkg.tar.gz.partial"); got != testContent {
+				t.Errorf("uploaded %q, want %q", got, testContent)
+			}
+		})
+	}
+}
+
+func TestUploadFileMismatchedPartial(t *testing.T) {
+	client := newTestClient(t)
+	client.config.Retries = 1
+	localPath := filepath.Join(t.TempDir(), "pkg.tar.gz")
+	if err := os.WriteFile(localPath, []byte(testContent), 0644); err != nil {
+		t.Fatalf("failed to write local file: %v", err)
+	}
+	// A partial file of another upload fails verification and is replaced
+	writeRemote(t, client, "/pkg.tar.gz.partial", "XXXXX")
+
+	if err := client.UploadFile(localPath, "/pkg.tar.gz", nil); err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+	if got := readRemote(t, client, "/pkg.tar.gz"); got != testContent {
+		t.Errorf("uploaded %q, want %q", got, testContent)
+	}
+	if exists, _ := client.FileExists("/pkg.tar.gz.partial"); exists {
+		t.Errorf("partial file left behind")
+	}
+}
+
+func TestOverwriteFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial string
+		content string
+	}{
+		{"longer content", testContent[:5], testContent},
+		{"shorter content truncates", testContent, testContent[:5]},
+		{"empty content", testContent, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t)
+			writeRemote(t, client, "/file", tt.initial)
+
+			if err := client.OverwriteFile("/file", []byte(tt.content)); err != nil {
+				t.Fatalf("OverwriteFile() error = %v", err)
+			}
+			if got := readRemote(t, client, "/file"); got != tt.content {
+				t.Errorf("content = %q, want %q", got, tt.content)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		client := newTestClient(t)
+		err := client.OverwriteFile("/file", []byte(testContent))
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("OverwriteFile() error = %v, want os.ErrNotExist", err)
+		}
+		if exists, _ := client.FileExists("/file"); exists {
+			t.Errorf("OverwriteFile() created a missing file")
+		}
+	})
+}
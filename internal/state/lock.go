@@ -0,0 +1,76 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rasadov/package-manager/internal/utils"
+)
+
+const (
+	lockTimeout       = 30 * time.Second
+	lockRetryInterval = 100 * time.Millisecond
+)
+
+// fileLock is an exclusive lock backed by a lock file
+type fileLock struct {
+	path string
+}
+
+// acquireLock creates the lock file exclusively, waiting for another
+// process to release it for up to lockTimeout. A lock left by a process
+// that is gone, e.g. after a crash, is taken over.
+func acquireLock(path string) (*fileLock, error) {
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintf(file, "%d\n", os.Getpid())
+			file.Close()
+			return &fileLock{path: path}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file %s: %w", path, err)
+		}
+		if !lockHeld(path) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("failed to remove stale lock file %s: %w", path, err)
+			}
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("state database is locked by another process (remove %s if it is stale)", path)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// lockHeld reports whether the process recorded in the lock file at path
+// is still running
+func lockHeld(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// The holder released the lock in the meantime
+		return !os.IsNotExist(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		// The holder is writing its PID, unless it died doing so long ago
+		info, statErr := os.Stat(path)
+		return statErr != nil || time.Since(info.ModTime()) < lockTimeout
+	}
+	return utils.ProcessAlive(pid)
+}
+
+// release removes the lock file
+func (l *fileLock) release() error {
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove lock file %s: %w", l.path, err)
+	}
+	return nil
+}
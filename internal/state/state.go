@@ -0,0 +1,247 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// DirName is the metadata directory created inside an install root
+	DirName = ".pm"
+	// FileName is the name of the state database inside DirName
+	FileName = "state.json"
+)
+
+// File represents a single file installed by a package
+type File struct {
+	Path     string `json:"path"`
+	Checksum string `json:"sha256"`
+	// Config marks configuration files, whose local edits survive upgrades
+	Config bool `json:"config,omitempty"`
+}
+
+// Package represents an installed package
+type Package struct {
+	Name        string    `json:"name"`
+	Version     string    `json:"ver"`
+	Archive     string    `json:"archive"`
+	Checksum    string    `json:"sha256"`
+	InstallDir  string    `json:"install_dir"`
+	InstalledAt time.Time `json:"installed_at"`
+	Files       []File    `json:"files"`
+	// Bins are the root-relative paths of the command links of the package
+	Bins []string `json:"bins,omitempty"`
+	// Pending are the root-relative paths of the new defaults staged next
+	// to locally edited config files
+	Pending []string `json:"pending,omitempty"`
+}
+
+// HistoryEntry records a change to an installed package
+type HistoryEntry struct {
+	Version  string    `json:"ver,omitempty"`
+	Archive  string    `json:"archive,omitempty"`
+	Checksum string    `json:"sha256,omitempty"`
+	Action   string    `json:"action"`
+	Time     time.Time `json:"time"`
+}
+
+// History actions
+const (
+	ActionInstall   = "install"
+	ActionRollback  = "rollback"
+	ActionUninstall = "uninstall"
+)
+
+// DB is the installed-package database of an install root
+type DB struct {
+	root     string
+	path     string
+	lock     *fileLock
+	Packages map[string]*Package       `json:"packages"`
+	History  map[string][]HistoryEntry `json:"history,omitempty"`
+}
+
+// Open loads the database of the given install root and locks it for writing.
+// The lock is held until Close is called.
+func Open(root string) (*DB, error) {
+	dir := filepath.Join(root, DirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	dbPath := filepath.Join(dir, FileName)
+	lock, err := acquireLock(dbPath + ".lock")
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := load(root, dbPath)
+	if err != nil {
+		lock.release()
+		return nil, err
+	}
+	db.lock = lock
+
+	return db, nil
+}
+
+// Load reads the database of the given install root without locking it.
+// A missing database is treated as empty.
+func Load(root string) (*DB, error) {
+	return load(root, filepath.Join(root, DirName, FileName))
+}
+
+// load reads the database file, returning an empty database if it does not exist
+func load(root, dbPath string) (*DB, error) {
+	db := &DB{
+		root:     root,
+		path:     dbPath,
+		Packages: make(map[string]*Package),
+	}
+
+	data, err := os.ReadFile(dbPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return db, nil
+		}
+		return nil, fmt.Errorf("failed to read state database: %w", err)
+	}
+
+	if err := json.Unmarshal(data, db); err != nil {
+		return nil, fmt.Errorf("failed to parse state database %s: %w", dbPath, err)
+	}
+	if db.Packages == nil {
+		db.Packages = make(map[string]*Package)
+	}
+
+	return db, nil
+}
+
+// Root returns the install root the database belongs to
+func (db *DB) Root() string {
+	return db.root
+}
+
+// Get returns the installed package with the given name
+func (db *DB) Get(name string) (*Package, bool) {
+	pkg, ok := db.Packages[name]
+	return pkg, ok
+}
+
+// Put records an installed package, replacing any previous record
+func (db *DB) Put(pkg *Package) {
+	db.Packages[pkg.Name] = pkg
+}
+
+// Remove deletes the record of an installed package
+func (db *DB) Remove(name string) {
+	delete(db.Packages, name)
+}
+
+// FileOwners maps every installed file and command link path to the
+// package that installed it
+func (db *DB) FileOwners() map[string]string {
+	owners := make(map[string]string)
+	for _, pkg := range db.Packages {
+		for _, file := range pkg.Files {
+			owners[file.Path] = pkg.Name
+		}
+		for _, bin := range pkg.Bins {
+			owners[bin] = pkg.Name
+		}
+	}
+	return owners
+}
+
+// DisownFiles removes the given paths from the file list of the named
+// package, for files taken over by another package
+func (db *DB) DisownFiles(name string, paths []string) {
+	pkg, ok := db.Packages[name]
+	if !ok {
+		return
+	}
+
+	disowned := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		disowned[path] = true
+	}
+
+	files := make([]File, 0, len(pkg.Files))
+	for _, file := range pkg.Files {
+		if !disowned[file.Path] {
+			files = append(files, file)
+		}
+	}
+	pkg.Files = files
+
+	var bins []string
+	for _, bin := range pkg.Bins {
+		if !disowned[bin] {
+			bins = append(bins, bin)
+		}
+	}
+	pkg.Bins = bins
+}
+
+// AddHistory appends an entry to the history of the named package
+func (db *DB) AddHistory(name string, entry HistoryEntry) {
+	if db.History == nil {
+		db.History = make(map[string][]HistoryEntry)
+	}
+	db.History[name] = append(db.History[name], entry)
+}
+
+// PackageHistory returns the history of the named package, oldest first
+func (db *DB) PackageHistory(name string) []HistoryEntry {
+	return db.History[name]
+}
+
+// List returns all installed packages sorted by name
+func (db *DB) List() []*Package {
+	packages := make([]*Package, 0, len(db.Packages))
+	for _, pkg := range db.Packages {
+		packages = append(packages, pkg)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
+	return packages
+}
+
+// Save writes the database to disk. The file is replaced atomically so
+// readers never observe a partially written database.
+func (db *DB) Save() error {
+	if db.lock == nil {
+		return fmt.Errorf("state database was opened read-only")
+	}
+
+	data, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state database: %w", err)
+	}
+
+	tmpPath := db.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state database: %w", err)
+	}
+	if err := os.Rename(tmpPath, db.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace state database: %w", err)
+	}
+
+	return nil
+}
+
+// Close releases the database lock
+func (db *DB) Close() error {
+	if db.lock == nil {
+		return nil
+	}
+	err := db.lock.release()
+	db.lock = nil
+	return err
+}
@@ -0,0 +1,198 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOpenEmptyRoot(t *testing.T) {
+	root := t.TempDir()
+
+	db, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	if len(db.List()) != 0 {
+		t.Errorf("expected empty database, got %d packages", len(db.List()))
+	}
+
+	if _, err := os.Stat(filepath.Join(root, DirName)); err != nil {
+		t.Errorf("state directory not created: %v", err)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	root := t.TempDir()
+
+	db, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	pkg := &Package{
+		Name:        "foo",
+		Version:     "1.2.0",
+		Archive:     "foo-1.2.0.tar.gz",
+		Checksum:    "abc123",
+		InstallDir:  "foo",
+		InstalledAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Files: []File{
+			{Path: "foo/bin/tool", Checksum: "def456"},
+		},
+	}
+	db.Put(pkg)
+	db.Put(&Package{Name: "bar", Version: "0.1"})
+
+	if err := db.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	loaded, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	got, ok := loaded.Get("foo")
+	if !ok {
+		t.Fatalf("package foo not found after reload")
+	}
+	if !reflect.DeepEqual(got, pkg) {
+		t.Errorf("got %+v, want %+v", got, pkg)
+	}
+
+	names := []string{}
+	for _, p := range loaded.List() {
+		names = append(names, p.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"bar", "foo"}) {
+		t.Errorf("List() = %v, want sorted [bar foo]", names)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	db, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	db.Put(&Package{Name: "foo"})
+	db.Remove("foo")
+
+	if _, ok := db.Get("foo"); ok {
+		t.Errorf("package foo still present after Remove()")
+	}
+}
+
+func TestFileOwnersAndDisownFiles(t *testing.T) {
+	db, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	db.Put(&Package{Name: "foo", Files: []File{{Path: "bin/foo"}, {Path: "bin/tool"}}})
+	db.Put(&Package{Name: "bar", Files: []File{{Path: "bin/bar"}}})
+
+	owners := db.FileOwners()
+	expected := map[string]string{"bin/foo": "foo", "bin/tool": "foo", "bin/bar": "bar"}
+	if !reflect.DeepEqual(owners, expected) {
+		t.Errorf("FileOwners() = %v, want %v", owners, expected)
+	}
+
+	db.DisownFiles("foo", []string{"bin/tool"})
+	foo, _ := db.Get("foo")
+	if !reflect.DeepEqual(foo.Files, []File{{Path: "bin/foo"}}) {
+		t.Errorf("DisownFiles() left %v", foo.Files)
+	}
+
+	// Unknown packages are ignored
+	db.DisownFiles("missing", []string{"bin/bar"})
+}
+
+func TestLoadReadOnly(t *testing.T) {
+	db, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if err := db.Save(); err == nil {
+		t.Errorf("expected Save() on read-only database to fail")
+	}
+}
+
+func TestLoadCorrupted(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, DirName), 0755)
+	os.WriteFile(filepath.Join(root, DirName, FileName), []byte("{invalid"), 0644)
+
+	_, err := Load(root)
+	if err == nil || !strings.Contains(err.Error(), "failed to parse state database") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
+
+func TestLockIsExclusive(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "state.json.lock")
+
+	lock, err := acquireLock(lockPath)
+	if err != nil {
+		t.Fatalf("acquireLock() error = %v", err)
+	}
+
+	var releasing atomic.Bool
+	go func() {
+		time.Sleep(2 * lockRetryInterval)
+		releasing.Store(true)
+		lock.release()
+	}()
+
+	// The second acquisition waits until the first lock is released
+	second, err := acquireLock(lockPath)
+	if err != nil {
+		t.Fatalf("second acquireLock() error = %v", err)
+	}
+	if !releasing.Load() {
+		t.Errorf("second lock acquired while first was still held")
+	}
+	second.release()
+
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("lock file still present after release")
+	}
+}
+
+func TestLockTakesOverStaleLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "state.json.lock")
+
+	// No process has this PID, as after a crash
+	if err := os.WriteFile(lockPath, []byte(strconv.Itoa(1<<30)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	lock, err := acquireLock(lockPath)
+	if err != nil {
+		t.Fatalf("acquireLock() error = %v", err)
+	}
+	defer lock.release()
+	if time.Since(start) > lockRetryInterval {
+		t.Errorf("acquireLock() waited %s for a stale lock", time.Since(start))
+	}
+
+	data, _ := os.ReadFile(lockPath)
+	if strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("lock file = %q, want the PID of this process", data)
+	}
+}
@@ -0,0 +1,104 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rasadov/package-manager/internal/utils"
+)
+
+// storeDirName is the directory inside DirName holding previously installed archives
+const storeDirName = "store"
+
+// storeDir returns the store directory of the named package
+func (db *DB) storeDir(name string) string {
+	return filepath.Join(db.root, DirName, storeDirName, name)
+}
+
+// StoreArchive copies an installed archive into the local store so the
+// version can be reinstalled later without network access
+func (db *DB) StoreArchive(name, archivePath string) error {
+	dir := db.storeDir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	storedPath := filepath.Join(dir, filepath.Base(archivePath))
+	if filepath.Clean(archivePath) != storedPath {
+		tmpPath := storedPath + ".tmp"
+		if err := utils.CopyFile(archivePath, tmpPath); err != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to copy archive to store: %w", err)
+		}
+		if err := os.Rename(tmpPath, storedPath); err != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to move archive into store: %w", err)
+		}
+	}
+
+	// The modification time orders stored archives by their last install
+	now := time.Now()
+	if err := os.Chtimes(storedPath, now, now); err != nil {
+		return fmt.Errorf("failed to update stored archive time: %w", err)
+	}
+
+	return nil
+}
+
+// StoredArchive returns the path of a stored archive of the named package
+func (db *DB) StoredArchive(name, archive string) (string, bool) {
+	storedPath := filepath.Join(db.storeDir(name), archive)
+	if _, err := os.Stat(storedPath); err != nil {
+		return "", false
+	}
+	return storedPath, true
+}
+
+// PruneStore keeps only the keep most recently installed archives of the
+// named package. The archive of the installed version is never removed.
+func (db *DB) PruneStore(name string, keep int) error {
+	entries, err := os.ReadDir(db.storeDir(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read store directory: %w", err)
+	}
+
+	type storedArchive struct {
+		name    string
+		modTime time.Time
+	}
+	var archives []storedArchive
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		archives = append(archives, storedArchive{name: entry.Name(), modTime: info.ModTime()})
+	}
+
+	// Newest first
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].modTime.After(archives[j].modTime)
+	})
+
+	installed := ""
+	if pkg, ok := db.Get(name); ok {
+		installed = pkg.Archive
+	}
+
+	for i, archive := range archives {
+		if i < keep || archive.name == installed {
+			continue
+		}
+		if err := os.Remove(filepath.Join(db.storeDir(name), archive.name)); err != nil {
+			return fmt.Errorf("failed to remove stored archive %s: %w", archive.name, err)
+		}
+	}
+
+	return nil
+}
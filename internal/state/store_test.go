@@ -0,0 +1,98 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func writeArchive(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	return path
+}
+
+func TestStoreArchive(t *testing.T) {
+	db, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	archive := writeArchive(t, t.TempDir(), "foo-1.0.0.tar.gz")
+	if err := db.StoreArchive("foo", archive); err != nil {
+		t.Fatalf("StoreArchive() error = %v", err)
+	}
+
+	storedPath, ok := db.StoredArchive("foo", "foo-1.0.0.tar.gz")
+	if !ok {
+		t.Fatalf("stored archive not found")
+	}
+	data, _ := os.ReadFile(storedPath)
+	if string(data) != "foo-1.0.0.tar.gz" {
+		t.Errorf("stored archive content = %q", data)
+	}
+
+	// Storing the stored archive again only refreshes it
+	if err := db.StoreArchive("foo", storedPath); err != nil {
+		t.Errorf("StoreArchive() of stored archive error = %v", err)
+	}
+
+	if _, ok := db.StoredArchive("foo", "foo-2.0.0.tar.gz"); ok {
+		t.Errorf("unexpected stored archive for version never stored")
+	}
+}
+
+func TestPruneStore(t *testing.T) {
+	db, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	archives := t.TempDir()
+	names := []string{"foo-1.0.0.tar.gz", "foo-1.1.0.tar.gz", "foo-1.2.0.tar.gz", "foo-1.3.0.tar.gz"}
+	base := time.Now().Add(-time.Hour)
+	for i, name := range names {
+		if err := db.StoreArchive("foo", writeArchive(t, archives, name)); err != nil {
+			t.Fatalf("StoreArchive() error = %v", err)
+		}
+		storedPath, _ := db.StoredArchive("foo", name)
+		stamp := base.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(storedPath, stamp, stamp)
+	}
+
+	// The installed version is kept even though it is the oldest
+	db.Put(&Package{Name: "foo", Version: "1.0.0", Archive: "foo-1.0.0.tar.gz"})
+
+	if err := db.PruneStore("foo", 2); err != nil {
+		t.Fatalf("PruneStore() error = %v", err)
+	}
+
+	entries, _ := os.ReadDir(db.storeDir("foo"))
+	var kept []string
+	for _, entry := range entries {
+		kept = append(kept, entry.Name())
+	}
+	sort.Strings(kept)
+
+	expected := []string{"foo-1.0.0.tar.gz", "foo-1.2.0.tar.gz", "foo-1.3.0.tar.gz"}
+	if len(kept) != len(expected) {
+		t.Fatalf("kept %v, want %v", kept, expected)
+	}
+	for i := range expected {
+		if kept[i] != expected[i] {
+			t.Errorf("kept %v, want %v", kept, expected)
+			break
+		}
+	}
+
+	if err := db.PruneStore("missing", 2); err != nil {
+		t.Errorf("PruneStore() of package without store error = %v", err)
+	}
+}
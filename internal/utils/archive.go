@@ -6,10 +6,30 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
+	"strings"
 )
 
+// MetadataDir is the archive directory holding package metadata such as the
+// manifest and lifecycle scripts. It is never extracted into the install directory.
+const MetadataDir = ".pm"
+
+// ArchiveEntry is an in-memory file added to an archive
+type ArchiveEntry struct {
+	Name string
+	Data []byte
+	Mode int64
+}
+
 // CreateTarGz creates a tar.gz archive from files matching the given patterns
 func CreateTarGz(includePatterns []string, excludePatterns []string, outputPath string) error {
+	return CreateTarGzWithEntries(includePatterns, excludePatterns, nil, outputPath)
+}
+
+// CreateTarGzWithEntries creates a tar.gz archive from files matching the given
+// patterns. The extra entries are written first so readers can find them
+// without scanning the whole archive.
+func CreateTarGzWithEntries(includePatterns []string, excludePatterns []string, entries []ArchiveEntry, outputPath string) error {
 	files, err := collectFilesByPatternsWithExclude(includePatterns, excludePatterns)
 	if err != nil {
 		return fmt.Errorf("failed to collect files: %w", err)
@@ -31,6 +51,21 @@ func CreateTarGz(includePatterns []string, excludePatterns []string, outputPath
 	tarWriter := tar.NewWriter(gzWriter)
 	defer tarWriter.Close()
 
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.Name,
+			Mode:     entry.Mode,
+			Size:     int64(len(entry.Data)),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write tar header for %s: %w", entry.Name, err)
+		}
+		if _, err := tarWriter.Write(entry.Data); err != nil {
+			return fmt.Errorf("failed to add %s to archive: %w", entry.Name, err)
+		}
+	}
+
 	for _, filePath := range files {
 		if err := addFileToTar(tarWriter, filePath); err != nil {
 			return fmt.Errorf("failed to add file %s to archive: %w", filePath, err)
@@ -65,6 +100,10 @@ func ExtractTarGz(archivePath, outputDir string) error {
 			return fmt.Errorf("failed to read tar header: %w", err)
 		}
 
+		if isMetadataEntry(header.Name) {
+			continue
+		}
+
 		if err := extractFileFromTar(tarReader, header, outputDir); err != nil {
 			return fmt.Errorf("failed to extract file %s: %w", header.Name, err)
 		}
@@ -72,3 +111,88 @@ func ExtractTarGz(archivePath, outputDir string) error {
 
 	return nil
 }
+
+// ListTarGz returns the paths of the regular files contained in a tar.gz
+// archive, leaving out package metadata
+func ListTarGz(archivePath string) ([]string, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer gzReader.Close()
+
+	tarReader := tar.NewReader(gzReader)
+
+	var files []string
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar header: %w", err)
+		}
+
+		if header.Typeflag == tar.TypeReg && !isMetadataEntry(header.Name) {
+			files = append(files, path.Clean(header.Name))
+		}
+	}
+
+	return files, nil
+}
+
+// ReadTarGzFile returns the content of a single file of a tar.gz archive.
+// The returned error wraps os.ErrNotExist if the archive has no such file.
+func ReadTarGzFile(archivePath, name string) ([]byte, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	return ReadTarGzEntry(file, name)
+}
+
+// ReadTarGzEntry returns the content of a single file of a tar.gz stream,
+// reading only as far as the file.
+// The returned error wraps os.ErrNotExist if the stream has no such file.
+func ReadTarGzEntry(r io.Reader, name string) ([]byte, error) {
+	gzReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer gzReader.Close()
+
+	tarReader := tar.NewReader(gzReader)
+	name = path.Clean(name)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s not found in archive: %w", name, os.ErrNotExist)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar header: %w", err)
+		}
+
+		if header.Typeflag == tar.TypeReg && path.Clean(header.Name) == name {
+			data, err := io.ReadAll(tarReader)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", name, err)
+			}
+			return data, nil
+		}
+	}
+}
+
+// isMetadataEntry reports whether an archive entry belongs to MetadataDir
+func isMetadataEntry(name string) bool {
+	clean := path.Clean(name)
+	return clean == MetadataDir || strings.HasPrefix(clean, MetadataDir+"/")
+}
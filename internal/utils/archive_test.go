@@ -3,6 +3,7 @@ package utils
 import (
 	"archive/tar"
 	"compress/gzip"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -249,6 +250,99 @@ func TestExtractTarGzSecurityCheck(t *testing.T) {
 	}
 }
 
+func TestListTarGz(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "pm-list-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	archivePath := filepath.Join(tempDir, "test.tar.gz")
+	testFiles := map[string]string{
+		"file1.txt":          "content of file 1",
+		"./subdir/file2.txt": "content of file 2",
+	}
+
+	if err := createTestArchive(archivePath, testFiles); err != nil {
+		t.Fatalf("Failed to create test archive: %v", err)
+	}
+
+	files, err := ListTarGz(archivePath)
+	if err != nil {
+		t.Fatalf("ListTarGz() error = %v", err)
+	}
+
+	sort.Strings(files)
+	expected := []string{"file1.txt", "subdir/file2.txt"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("ListTarGz() = %v, want %v", files, expected)
+	}
+}
+
+func TestArchiveMetadataEntries(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "pm-metadata-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	oldDir, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(oldDir)
+
+	os.MkdirAll("src", 0755)
+	os.WriteFile("src/main.go", []byte("package main"), 0644)
+
+	archivePath := filepath.Join(tempDir, "test.tar.gz")
+	entries := []ArchiveEntry{
+		{Name: ".pm/packet.json", Data: []byte(`{"name":"test"}`), Mode: 0644},
+	}
+	if err := CreateTarGzWithEntries([]string{"src/*"}, nil, entries, archivePath); err != nil {
+		t.Fatalf("CreateTarGzWithEntries() error = %v", err)
+	}
+
+	// Metadata comes first in the archive
+	contents, err := readTarGzContents(archivePath)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	if len(contents) != 2 || contents[0] != ".pm/packet.json" {
+		t.Errorf("archive contents = %v, want metadata first", contents)
+	}
+
+	data, err := ReadTarGzFile(archivePath, ".pm/packet.json")
+	if err != nil {
+		t.Fatalf("ReadTarGzFile() error = %v", err)
+	}
+	if string(data) != `{"name":"test"}` {
+		t.Errorf("ReadTarGzFile() = %q", data)
+	}
+
+	if _, err := ReadTarGzFile(archivePath, ".pm/missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadTarGzFile() for missing file error = %v, want os.ErrNotExist", err)
+	}
+
+	// Metadata is neither listed nor extracted
+	files, err := ListTarGz(archivePath)
+	if err != nil {
+		t.Fatalf("ListTarGz() error = %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"src/main.go"}) {
+		t.Errorf("ListTarGz() = %v, want [src/main.go]", files)
+	}
+
+	extractDir := filepath.Join(tempDir, "extracted")
+	if err := ExtractTarGz(archivePath, extractDir); err != nil {
+		t.Fatalf("ExtractTarGz() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(extractDir, MetadataDir)); !os.IsNotExist(err) {
+		t.Errorf("metadata directory was extracted")
+	}
+	if _, err := os.Stat(filepath.Join(extractDir, "src/main.go")); err != nil {
+		t.Errorf("package file not extracted: %v", err)
+	}
+}
+
 // Helper function to read tar.gz contents without extracting
 func readTarGzContents(archivePath string) ([]string, error) {
 	file, err := os.Open(archivePath)
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileChecksum(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "file.txt")
+	if err := os.WriteFile(filePath, []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	checksum, err := FileChecksum(filePath)
+	if err != nil {
+		t.Fatalf("FileChecksum() error = %v", err)
+	}
+
+	// sha256 of "hello\n"
+	expected := "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+	if checksum != expected {
+		t.Errorf("FileChecksum() = %s, want %s", checksum, expected)
+	}
+
+	if _, err := FileChecksum(filepath.Join(tempDir, "missing")); err == nil {
+		t.Errorf("FileChecksum() expected error for missing file")
+	}
+}
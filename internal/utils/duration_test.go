@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    time.Duration
+		expectError bool
+	}{
+		{input: "30d", expected: 30 * 24 * time.Hour},
+		{input: "0d", expected: 0},
+		{input: "12h", expected: 12 * time.Hour},
+		{input: "1h30m", expected: 90 * time.Minute},
+		{input: "xd", expectError: true},
+		{input: "-1d", expectError: true},
+		{input: "soon", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ParseDuration(tt.input)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("ParseDuration() expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("ParseDuration() unexpected error: %v", err)
+				return
+			}
+
+			if result != tt.expected {
+				t.Errorf("ParseDuration() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
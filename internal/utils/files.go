@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // addFileToTar adds a single file to the tar archive while preserving directory structure
@@ -36,6 +37,14 @@ func addFileToTar(tarWriter *tar.Writer, filePath string) error {
 	// Use the archive name in the tar header
 	header.Name = archiveName
 
+	// Leave out timestamps and ownership so rebuilding unchanged files
+	// yields the same archive bytes and checksum
+	header.ModTime = time.Time{}
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.Uid, header.Gid = 0, 0
+	header.Uname, header.Gname = "", ""
+
 	if err := tarWriter.WriteHeader(header); err != nil {
 		return fmt.Errorf("failed to write tar header: %w", err)
 	}
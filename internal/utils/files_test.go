@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetArchiveName(t *testing.T) {
@@ -196,6 +197,38 @@ func TestAddFileToTar(t *testing.T) {
 	verifyTarContents(t, archivePath, testFiles)
 }
 
+func TestCreateTarGzIsReproducible(t *testing.T) {
+	tempDir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(oldDir)
+
+	if err := os.WriteFile("main.go", []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	build := func(name string, modTime time.Time) string {
+		if err := os.Chtimes("main.go", modTime, modTime); err != nil {
+			t.Fatalf("Failed to touch test file: %v", err)
+		}
+		archivePath := filepath.Join(tempDir, name)
+		if err := CreateTarGz([]string{"main.go"}, nil, archivePath); err != nil {
+			t.Fatalf("CreateTarGz() error = %v", err)
+		}
+		checksum, err := FileChecksum(archivePath)
+		if err != nil {
+			t.Fatalf("FileChecksum() error = %v", err)
+		}
+		return checksum
+	}
+
+	first := build("first.tar.gz", time.Now().Add(-time.Hour))
+	second := build("second.tar.gz", time.Now())
+	if first != second {
+		t.Errorf("rebuilding unchanged files changed the checksum: %s != %s", first, second)
+	}
+}
+
 func TestAddFileToTarErrors(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "pm-add-file-error-test-*")
 	if err != nil {
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// ProcessAlive reports whether a process with the given PID is running
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// rateUnits are the multipliers of transfer rate units. Like curl, K, M
+// and G are powers of 1024 with or without a B.
+var rateUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1 << 10,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1 << 20,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1 << 30,
+	"gib": 1 << 30,
+}
+
+// ParseRate parses a transfer rate such as "2MB/s", "500K" or "1048576"
+// into bytes per second. An empty rate is 0, meaning unlimited.
+func ParseRate(s string) (int64, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	value = strings.TrimSuffix(value, "/s")
+	if value == "" {
+		return 0, nil
+	}
+
+	end := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if end < 0 {
+		end = len(value)
+	}
+	n, err := strconv.ParseFloat(value[:end], 64)
+	unit, ok := rateUnits[strings.TrimSpace(value[end:])]
+	if err != nil || !ok || n < 0 {
+		return 0, fmt.Errorf("invalid rate: %s", s)
+	}
+
+	return int64(n * unit), nil
+}
@@ -0,0 +1,44 @@
+package utils
+
+import "testing"
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    int64
+		expectError bool
+	}{
+		{input: "", expected: 0},
+		{input: "2MB/s", expected: 2 * 1024 * 1024},
+		{input: "500K", expected: 500 * 1024},
+		{input: "1.5 MiB/s", expected: 1536 * 1024},
+		{input: "1g", expected: 1024 * 1024 * 1024},
+		{input: "4096", expected: 4096},
+		{input: "0", expected: 0},
+		{input: "fast", expectError: true},
+		{input: "2TB/s", expectError: true},
+		{input: "MB/s", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ParseRate(tt.input)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("ParseRate() expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("ParseRate() unexpected error: %v", err)
+				return
+			}
+
+			if result != tt.expected {
+				t.Errorf("ParseRate() = %d, want %d", result, tt.expected)
+			}
+		})
+	}
+}